@@ -37,30 +37,18 @@ func NewCamera(pos, forward, up Vector3f, mouseSensitivity float32) *Camera {
 	c.forward = forward.normalised()
 	c.up = up.normalised()
 	c.mouseSensitivity = mouseSensitivity
-	w, h := Window.GetSize()
-	c.fov, c.width, c.height, c.zNear, c.zFar = 70, float32(w), float32(h), 0.01, 1000.0
+	c.fov, c.width, c.height, c.zNear, c.zFar = 70, windowWidth, windowHeight, 0.01, 1000.0
 
 	return &c
 }
 
-func (c *Camera) mouseLook(oldPosition *Vector2f) {
-	x, y := Window.GetCursorPos()
-	newPosition := Vector2f{float32(x), float32(y)}
-	deltaPos := newPosition.sub(*oldPosition)
-
-	rotY := deltaPos.X != 0
-	rotX := deltaPos.Y != 0
-
-	if rotY {
+func (c *Camera) mouseLook(deltaPos Vector2f) {
+	if deltaPos.X != 0 {
 		c.rotateY(deltaPos.X * c.mouseSensitivity)
 	}
-	if rotX {
+	if deltaPos.Y != 0 {
 		c.rotateX(deltaPos.Y * c.mouseSensitivity)
 	}
-
-	if rotY || rotX {
-		*oldPosition = newPosition
-	}
 }
 
 func (c *Camera) rotateY(angle float32) {
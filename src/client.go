@@ -0,0 +1,285 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// max number of input frames waiting to be acknowledged by the server
+	maxPendingInput = 250
+	// join requests are repeated with this interval until the server answers
+	joinRetryInterval = 500 * time.Millisecond
+	joinTimeout       = 10 * time.Second
+)
+
+// netClient keeps a local copy of a game simulated by a server: the local player movement
+// is predicted by replaying the input not yet acknowledged on top of the last snapshot received,
+// while everything else is set from snapshots.
+type netClient struct {
+	conn   *net.UDPConn
+	source inputSource
+	player int
+	game   *Game
+
+	seq     uint32
+	pending []netInputFrame
+
+	// last snapshot received, nil once applied
+	mu       sync.Mutex
+	latest   *snapshot
+	lastTick uint32
+	wg       sync.WaitGroup
+}
+
+// connect joins the game of the server at the specified address, with local input from source.
+func connect(addr string, source inputSource) (*Game, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, netError{addr, err}
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, netError{addr, err}
+	}
+
+	c := &netClient{conn: conn, source: source}
+	w, err := c.join()
+	if err != nil {
+		conn.Close()
+		return nil, netError{addr, err}
+	}
+	if w.NumPlayers == 0 || w.NumPlayers > maxPlayers || w.Player >= w.NumPlayers {
+		conn.Close()
+		return nil, netError{addr, fmt.Errorf("invalid player %d of %d", w.Player, w.NumPlayers)}
+	}
+	err = checkMapName(w.mapName())
+	if err != nil {
+		conn.Close()
+		return nil, netError{addr, err}
+	}
+	fmt.Printf("joined as player %d of %d\n", w.Player+1, w.NumPlayers)
+
+	g := &Game{client: c, deathmatch: w.Deathmatch != 0}
+	g.seedRandom(w.Seed, 0)
+	// players are all moved by the server
+	g.inputSources = make([]inputSource, w.NumPlayers)
+	c.game, c.player = g, int(w.Player)
+
+	err = g.loadLevel(w.mapName(), uint(w.Level), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.wg.Add(1)
+	go c.read()
+
+	return g, nil
+}
+
+func (c *netClient) join() (*netWelcomeMessage, error) {
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, netMaxPacketSize)
+	deadline := time.Now().Add(joinTimeout)
+	for time.Now().Before(deadline) {
+		_, err := c.conn.Write(newNetMessage(netMsgJoin).Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		c.conn.SetReadDeadline(time.Now().Add(joinRetryInterval))
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		r := bytes.NewReader(buf[:n])
+		t, err := readNetMessage(r)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case netMsgWelcome:
+			var w netWelcomeMessage
+			err = binary.Read(r, binary.LittleEndian, &w)
+			if err != nil {
+				return nil, err
+			}
+			return &w, nil
+		case netMsgFull:
+			return nil, errServerFull
+		}
+	}
+	return nil, errors.New("no answer from server")
+}
+
+// read receives snapshots until the connection is closed.
+func (c *netClient) read() {
+	defer c.wg.Done()
+
+	buf := make([]byte, netMaxPacketSize)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			// connection closed, or server gone
+			return
+		}
+		r := bytes.NewReader(buf[:n])
+		t, err := readNetMessage(r)
+		if err != nil || t != netMsgSnapshot {
+			continue
+		}
+		s, err := decodeSnapshot(r)
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		// datagrams can arrive out of order
+		if s.Tick >= c.lastTick {
+			c.latest, c.lastTick = s, s.Tick
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *netClient) takeSnapshot() *snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.latest
+	c.latest = nil
+	return s
+}
+
+func (c *netClient) localPlayer() *Player {
+	return c.game.level.players[c.player]
+}
+
+// input sends the local input to the server and starts predicting its effect.
+func (c *netClient) input() error {
+	in, err := c.source.next()
+	if err != nil {
+		return err
+	}
+
+	// orientation is sent instead of mouse movements, so that the server does not need
+	// to replicate the same rotations
+	p := c.localPlayer()
+	p.camera.mouseLook(in.mouseDelta)
+
+	c.seq++
+	f := netInputFrame{
+		Seq:     c.seq,
+		Buttons: in.buttons,
+		Weapon:  in.weapon,
+		Forward: toArray(p.camera.forward),
+		Up:      toArray(p.camera.up),
+	}
+	c.pending = append(c.pending, f)
+	if len(c.pending) > maxPendingInput {
+		c.pending = c.pending[len(c.pending)-maxPendingInput:]
+	}
+
+	_, err = c.conn.Write(encodeInput(c.pending))
+	if err != nil {
+		return netError{c.conn.RemoteAddr().String(), err}
+	}
+
+	if !p.dead() {
+		p.steer(f.frame())
+	}
+	return nil
+}
+
+// update applies the last snapshot received, if any, and predicts the local player position.
+func (c *netClient) update() error {
+	g := c.game
+	s := c.takeSnapshot()
+	if s == nil {
+		p := c.localPlayer()
+		if !p.dead() {
+			p.update()
+		}
+		return nil
+	}
+
+	if uint(s.Loads) != g.levelLoads {
+		// next level, or level restarted
+		err := checkMapName(s.mapName())
+		if err != nil {
+			return netError{c.conn.RemoteAddr().String(), err}
+		}
+		err = g.loadLevel(s.mapName(), uint(s.Level), g.level)
+		if err != nil {
+			return err
+		}
+		g.levelLoads = uint(s.Loads)
+	}
+
+	err := s.apply(g.level, c.player)
+	if err != nil {
+		return netError{c.conn.RemoteAddr().String(), err}
+	}
+	g.clock = time.Duration(s.Tick) * frameTime
+	if state := gameState(s.State); state != g.state {
+		if (state == gameLevelComplete || state == gameVictory) && !g.deathmatch {
+			// scores received already include the bonus
+			t := g.level.stats()
+			t.Bonus = t.bonus()
+			g.level.tally = &t
+		}
+		g.setState(state)
+	}
+
+	// start from the authoritative position and replay the input not yet applied by the server
+	p := c.localPlayer()
+	ps := s.players[c.player]
+	p.camera.pos.X, p.camera.pos.Z = ps.X, ps.Z
+	for len(c.pending) > 0 && c.pending[0].Seq <= s.Ack {
+		c.pending = c.pending[1:]
+	}
+	if p.dead() {
+		return nil
+	}
+	for _, f := range c.pending {
+		p.steer(f.frame())
+		p.update()
+	}
+
+	return nil
+}
+
+// Close disconnects from the server.
+func (c *netClient) Close() error {
+	err := c.conn.Close()
+	c.wg.Wait()
+	return err
+}
@@ -0,0 +1,92 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package main
+
+import "testing"
+
+func TestCoopPlayersStart(t *testing.T) {
+	h := newCoopHarness(t, 1)
+
+	if len(h.level().players) != 2 {
+		t.Fatalf("expected 2 players but got %d", len(h.level().players))
+	}
+	p1, p2 := h.player(), h.player2()
+	if p1.camera.pos != (Vector3f{9.5, 0.4375, 24.5}) || p2.camera.pos != (Vector3f{10.5, 0.4375, 24.5}) {
+		t.Errorf("unexpected start positions %s and %s", p1.camera.pos.String(), p2.camera.pos.String())
+	}
+	if p1.camera.height != windowHeight/2 || p2.camera.height != windowHeight/2 {
+		t.Errorf("cameras should have half-height viewports")
+	}
+
+	// the second player start is ignored in single player mode
+	h = newHarness(t, 1)
+	if len(h.level().players) != 1 {
+		t.Errorf("expected a single player but got %d", len(h.level().players))
+	}
+}
+
+func TestCoopSeparateInput(t *testing.T) {
+	h := newCoopHarness(t, 1)
+	h.removeMonsters()
+
+	start1, start2 := h.player().camera.pos, h.player2().camera.pos
+	h.script2.hold(inputBack, 100)
+	h.runScript()
+
+	if h.player().camera.pos != start1 {
+		t.Errorf("first player moved with the input of the second one")
+	}
+	if h.player2().camera.pos == start2 {
+		t.Errorf("second player did not move")
+	}
+}
+
+func TestMonsterTargetsNearestVisiblePlayer(t *testing.T) {
+	h := newCoopHarness(t, 1)
+
+	monster := h.monsterAt(13, 28)
+	// the second player is nearer but behind walls
+	movePlayer(h.player(), 13.6, 24.5, towardsPlusZ)
+	movePlayer(h.player2(), 11.5, 28.5, towardsPlusZ)
+
+	if target := h.level().targetPlayer(monster.transform.translation); target != h.player() {
+		t.Fatal("monster should target the visible player")
+	}
+
+	// dead players are ignored
+	h.player().health = 0
+	if target := h.level().targetPlayer(monster.transform.translation); target != h.player2() {
+		t.Error("monster should target the living player")
+	}
+}
+
+func TestCoopGameOver(t *testing.T) {
+	h := newCoopHarness(t, 1)
+	h.removeMonsters()
+
+	h.player().damage(defaultPlayer.maxHealth)
+	if h.g.state != gamePlaying {
+		t.Fatal("game should go on while a player is alive")
+	}
+	h.player2().damage(defaultPlayer.maxHealth)
+	if h.g.state != gameOver {
+		t.Error("game should be over when all players are dead")
+	}
+}
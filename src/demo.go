@@ -0,0 +1,225 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// demo files are text files, starting with a header followed by one line per tick:
+//
+//	WDEMO 2
+//	level 1
+//	map level1.map
+//	seed 1547332021
+//	01 0 0 0
+//	11 2 -3 0.5
+//
+// tick lines contain the pressed buttons (hexadecimal), the selected weapon slot and the mouse delta;
+// version 1 demos have no weapon slot.
+const (
+	demoMagic   = "WDEMO"
+	demoVersion = 2
+)
+
+var errDemoFinished = errors.New("demo playback finished")
+
+type demoError struct {
+	fileName string
+	err      error
+}
+
+func (de demoError) Error() string {
+	return fmt.Sprintf("demo(%s): %v", de.fileName, de.err)
+}
+
+type demoHeader struct {
+	level   uint
+	mapName string
+	seed    int64
+}
+
+// demoPlayer is an input source reading frames from a demo file.
+type demoPlayer struct {
+	demoHeader
+	version int
+	f       *os.File
+	scanner *bufio.Scanner
+	lineNum int
+}
+
+func openDemo(fileName string) (*demoPlayer, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, demoError{fileName, err}
+	}
+
+	dp := &demoPlayer{f: f, scanner: bufio.NewScanner(f)}
+	err = dp.readHeader()
+	if err != nil {
+		f.Close()
+		return nil, demoError{fileName, err}
+	}
+
+	return dp, nil
+}
+
+func (dp *demoPlayer) readLine() (string, bool) {
+	if !dp.scanner.Scan() {
+		return "", false
+	}
+	dp.lineNum++
+	return dp.scanner.Text(), true
+}
+
+func (dp *demoPlayer) readHeader() error {
+	line, _ := dp.readLine()
+	_, err := fmt.Sscanf(line, demoMagic+" %d", &dp.version)
+	if err != nil {
+		return fmt.Errorf("not a demo file: %v", err)
+	}
+	if dp.version < 1 || dp.version > demoVersion {
+		return fmt.Errorf("unsupported demo version %d", dp.version)
+	}
+
+	line, _ = dp.readLine()
+	_, err = fmt.Sscanf(line, "level %d", &dp.level)
+	if err != nil {
+		return fmt.Errorf("invalid level declaration at line %d: %v", dp.lineNum, err)
+	}
+	line, _ = dp.readLine()
+	_, err = fmt.Sscanf(line, "map %s", &dp.mapName)
+	if err != nil {
+		return fmt.Errorf("invalid map declaration at line %d: %v", dp.lineNum, err)
+	}
+	line, _ = dp.readLine()
+	_, err = fmt.Sscanf(line, "seed %d", &dp.seed)
+	if err != nil {
+		return fmt.Errorf("invalid seed declaration at line %d: %v", dp.lineNum, err)
+	}
+
+	return dp.scanner.Err()
+}
+
+func (dp *demoPlayer) next() (inputFrame, error) {
+	var in inputFrame
+
+	line, ok := dp.readLine()
+	if !ok {
+		if err := dp.scanner.Err(); err != nil {
+			return in, demoError{dp.f.Name(), err}
+		}
+		return in, errDemoFinished
+	}
+
+	fields := strings.Fields(line)
+	if dp.version == 1 && len(fields) == 3 {
+		fields = append(fields[:1:1], append([]string{"0"}, fields[1:]...)...)
+	}
+	if len(fields) != 4 {
+		return in, demoError{dp.f.Name(), fmt.Errorf("invalid tick at line %d", dp.lineNum)}
+	}
+	buttons, err := strconv.ParseUint(fields[0], 16, 8)
+	if err != nil {
+		return in, demoError{dp.f.Name(), fmt.Errorf("invalid buttons at line %d: %v", dp.lineNum, err)}
+	}
+	weapon, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil || weapon > uint64(numWeapons) {
+		return in, demoError{dp.f.Name(), fmt.Errorf("invalid weapon slot at line %d", dp.lineNum)}
+	}
+	x, err := strconv.ParseFloat(fields[2], 32)
+	if err != nil {
+		return in, demoError{dp.f.Name(), fmt.Errorf("invalid mouse delta at line %d: %v", dp.lineNum, err)}
+	}
+	y, err := strconv.ParseFloat(fields[3], 32)
+	if err != nil {
+		return in, demoError{dp.f.Name(), fmt.Errorf("invalid mouse delta at line %d: %v", dp.lineNum, err)}
+	}
+
+	in.buttons = inputButtons(buttons)
+	in.weapon = uint8(weapon)
+	in.mouseDelta = Vector2f{float32(x), float32(y)}
+
+	return in, nil
+}
+
+func (dp *demoPlayer) Close() error {
+	return dp.f.Close()
+}
+
+// demoRecorder is an input source that writes to a demo file all frames read from another source.
+type demoRecorder struct {
+	source inputSource
+	f      *os.File
+	w      *bufio.Writer
+}
+
+func createDemo(fileName string, h demoHeader, source inputSource) (*demoRecorder, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, demoError{fileName, err}
+	}
+
+	dr := &demoRecorder{source: source, f: f, w: bufio.NewWriter(f)}
+	_, err = fmt.Fprintf(dr.w, "%s %d\nlevel %d\nmap %s\nseed %d\n", demoMagic, demoVersion, h.level, h.mapName, h.seed)
+	if err != nil {
+		f.Close()
+		return nil, demoError{fileName, err}
+	}
+
+	return dr, nil
+}
+
+func (dr *demoRecorder) next() (inputFrame, error) {
+	in, err := dr.source.next()
+	if err != nil {
+		return in, err
+	}
+
+	_, err = fmt.Fprintf(dr.w, "%02x %d %s %s\n", in.buttons, in.weapon, formatFloat32(in.mouseDelta.X), formatFloat32(in.mouseDelta.Y))
+	if err != nil {
+		return in, demoError{dr.f.Name(), err}
+	}
+
+	return in, nil
+}
+
+func (dr *demoRecorder) Close() error {
+	if c, ok := dr.source.(io.Closer); ok {
+		c.Close()
+	}
+
+	err := dr.w.Flush()
+	if err != nil {
+		dr.f.Close()
+		return demoError{dr.f.Name(), err}
+	}
+	return dr.f.Close()
+}
+
+// formatFloat32 formats f with the minimum precision that allows parsing back the exact same value.
+func formatFloat32(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
@@ -37,7 +37,7 @@ type Door struct {
 	transform                                               *Transform
 	openPosition, closePosition                             Vector3f
 	isOpening                                               bool
-	openingStartTime, openTime, closingStartTime, closeTime time.Time
+	openingStartTime, openTime, closingStartTime, closeTime time.Duration // game clock times
 
 	game *Game
 }
@@ -99,16 +99,16 @@ func (d *Door) open() {
 		return
 	}
 
-	d.openingStartTime = time.Now()
-	d.openTime = d.openingStartTime.Add(timeToOpen)
-	d.closingStartTime = d.openTime.Add(closeDelay)
-	d.closeTime = d.closingStartTime.Add(timeToOpen)
+	d.openingStartTime = d.game.clock
+	d.openTime = d.openingStartTime + timeToOpen
+	d.closingStartTime = d.openTime + closeDelay
+	d.closeTime = d.closingStartTime + timeToOpen
 
 	d.isOpening = true
 }
 
-func getIncrements(now, target time.Time, delta time.Duration) float32 {
-	t := float32(now.Sub(target).Nanoseconds())
+func getIncrements(now, target, delta time.Duration) float32 {
+	t := float32((now - target).Nanoseconds())
 
 	return t / float32(delta.Nanoseconds())
 }
@@ -119,13 +119,13 @@ func vectorLerp(startPos, endPos Vector3f, lerpFactor float32) Vector3f {
 
 func (d *Door) update() {
 	if d.isOpening {
-		now := time.Now()
+		now := d.game.clock
 
-		if now.Before(d.openTime) {
+		if now < d.openTime {
 			d.transform.translation = vectorLerp(d.closePosition, d.openPosition, getIncrements(now, d.openingStartTime, timeToOpen))
-		} else if now.Before(d.closingStartTime) {
+		} else if now < d.closingStartTime {
 			d.transform.translation = d.openPosition
-		} else if now.Before(d.closeTime) {
+		} else if now < d.closeTime {
 			d.transform.translation = vectorLerp(d.openPosition, d.closePosition, getIncrements(now, d.closingStartTime, timeToOpen))
 		} else {
 			d.transform.translation = d.closePosition
@@ -134,8 +134,8 @@ func (d *Door) update() {
 	}
 }
 
-func (d *Door) render() {
-	t := d.transform.getProjectedTransformation(d.game.Camera())
+func (d *Door) render(c *Camera) {
+	t := d.transform.getProjectedTransformation(c)
 	d.game.level.shader.updateUniforms(t, d.material)
 	d.mesh.draw()
 }
@@ -0,0 +1,147 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+const (
+	glyphWidth, glyphHeight = 5, 7
+	// glyphs are laid out in the font texture in cells with one pixel of spacing
+	glyphCellWidth, glyphCellHeight = glyphWidth + 1, glyphHeight + 1
+	glyphsPerRow                    = 16
+)
+
+// fontGlyphs is the pixel art of the HUD font, rows from top to bottom separated by spaces;
+// only uppercase letters are available, text is converted when drawn.
+var fontGlyphs = map[rune]string{
+	'0': ".###. #...# #..## #.#.# ##..# #...# .###.",
+	'1': "..#.. .##.. ..#.. ..#.. ..#.. ..#.. .###.",
+	'2': ".###. #...# ....# ...#. ..#.. .#... #####",
+	'3': "##### ...#. ..#.. ...#. ....# #...# .###.",
+	'4': "...#. ..##. .#.#. #..#. ##### ...#. ...#.",
+	'5': "##### #.... ####. ....# ....# #...# .###.",
+	'6': "..##. .#... #.... ####. #...# #...# .###.",
+	'7': "##### ....# ...#. ..#.. .#... .#... .#...",
+	'8': ".###. #...# #...# .###. #...# #...# .###.",
+	'9': ".###. #...# #...# .#### ....# ...#. .##..",
+	'A': ".###. #...# #...# ##### #...# #...# #...#",
+	'B': "####. #...# #...# ####. #...# #...# ####.",
+	'C': ".###. #...# #.... #.... #.... #...# .###.",
+	'D': "###.. #..#. #...# #...# #...# #..#. ###..",
+	'E': "##### #.... #.... ####. #.... #.... #####",
+	'F': "##### #.... #.... ####. #.... #.... #....",
+	'G': ".###. #...# #.... #.### #...# #...# .####",
+	'H': "#...# #...# #...# ##### #...# #...# #...#",
+	'I': ".###. ..#.. ..#.. ..#.. ..#.. ..#.. .###.",
+	'J': "..### ...#. ...#. ...#. ...#. #..#. .##..",
+	'K': "#...# #..#. #.#.. ##... #.#.. #..#. #...#",
+	'L': "#.... #.... #.... #.... #.... #.... #####",
+	'M': "#...# ##.## #.#.# #.#.# #...# #...# #...#",
+	'N': "#...# #...# ##..# #.#.# #..## #...# #...#",
+	'O': ".###. #...# #...# #...# #...# #...# .###.",
+	'P': "####. #...# #...# ####. #.... #.... #....",
+	'Q': ".###. #...# #...# #...# #.#.# #..#. .##.#",
+	'R': "####. #...# #...# ####. #.#.. #..#. #...#",
+	'S': ".#### #.... #.... .###. ....# ....# ####.",
+	'T': "##### ..#.. ..#.. ..#.. ..#.. ..#.. ..#..",
+	'U': "#...# #...# #...# #...# #...# #...# .###.",
+	'V': "#...# #...# #...# #...# #...# .#.#. ..#..",
+	'W': "#...# #...# #...# #.#.# #.#.# #.#.# .#.#.",
+	'X': "#...# #...# .#.#. ..#.. .#.#. #...# #...#",
+	'Y': "#...# #...# .#.#. ..#.. ..#.. ..#.. ..#..",
+	'Z': "##### ....# ...#. ..#.. .#... #.... #####",
+	'%': "##... ##..# ...#. ..#.. .#... #..## ...##",
+	'-': "..... ..... ..... ##### ..... ..... .....",
+	':': "..... .##.. .##.. ..... .##.. .##.. .....",
+	'!': "..#.. ..#.. ..#.. ..#.. ..#.. ..... ..#..",
+	'.': "..... ..... ..... ..... ..... .##.. .##..",
+	'/': "..... ....# ...#. ..#.. .#... #.... .....",
+	'?': ".###. #...# ....# ...#. ..#.. ..... ..#..",
+	'>': ".#... ..#.. ...#. ....# ...#. ..#.. .#...",
+	'<': "...#. ..#.. .#... #.... .#... ..#.. ...#.",
+}
+
+// bitmapFont draws text with the glyphs of a texture generated from fontGlyphs;
+// there is one mesh for each glyph, mapping its part of the texture.
+type bitmapFont struct {
+	material *Material
+	glyphs   map[rune]Mesh
+}
+
+func newBitmapFont() *bitmapFont {
+	runes := make([]rune, 0, len(fontGlyphs))
+	for r := range fontGlyphs {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	rows := (len(runes) + glyphsPerRow - 1) / glyphsPerRow
+	img := image.NewNRGBA(image.Rect(0, 0, glyphsPerRow*glyphCellWidth, rows*glyphCellHeight))
+	size := img.Bounds().Size()
+
+	f := &bitmapFont{glyphs: make(map[rune]Mesh, len(runes))}
+	for i, r := range runes {
+		x0, y0 := (i%glyphsPerRow)*glyphCellWidth, (i/glyphsPerRow)*glyphCellHeight
+		for y, row := range strings.Fields(fontGlyphs[r]) {
+			for x, c := range row {
+				if c == '#' {
+					img.SetNRGBA(x0+x, y0+y, color.NRGBA{255, 255, 255, 255})
+				}
+			}
+		}
+
+		f.glyphs[r] = newQuad(
+			float32(x0)/float32(size.X), float32(y0)/float32(size.Y),
+			float32(x0+glyphWidth)/float32(size.X), float32(y0+glyphHeight)/float32(size.Y))
+	}
+	f.material = NewMaterial(NewTextureFromImage(img))
+
+	return f
+}
+
+// textWidth returns the width of the text drawn with the specified scale.
+func textWidth(text string, scale float32) float32 {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return float32(n*glyphCellWidth-1) * scale
+}
+
+// drawText draws text on an overlay of the specified size, with the bottom left corner
+// of the first glyph at x, y; each glyph pixel is scale overlay units wide.
+func (f *bitmapFont) drawText(s *Shader, width, height, x, y, scale float32, text string, c Vector3f) {
+	f.material.color = c
+
+	var t Transform
+	t.scale = Vector3f{glyphWidth * scale, glyphHeight * scale, 1}
+	for _, r := range strings.ToUpper(text) {
+		if mesh, ok := f.glyphs[r]; ok {
+			t.translation = Vector3f{x, y, 0}
+			s.updateUniforms(t.getOrthoTransformation(width, height), f.material)
+			mesh.draw()
+		}
+		x += glyphCellWidth * scale
+	}
+}
@@ -18,22 +18,79 @@ with this program; if not, write to the Free Software Foundation, Inc.,
 */
 package main
 
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+type gameState int
+
+const (
+	gamePlaying gameState = iota
+	// all players are dead: the level or the whole game can be restarted
+	gameOver
+	// the exit has been reached, the next level starts once the player is ready
+	gameLevelComplete
+	// the last level has been completed, the game can only be restarted
+	gameVictory
+)
+
 type Game struct {
-	level     *Level
-	isRunning bool
-	levelNum  uint
+	level    *Level
+	state    gameState
+	levelNum uint
+	// set while a menu is shown: the simulation does not advance
+	paused bool
+	// file name of the current level map
+	mapName string
 
-	// mouse look fields
-	oldPosition Vector2f
-	mouseLocked bool
+	// one per player; entries are nil when there is no input, e.g. in headless mode
+	inputSources []inputSource
+	// buttons pressed in the previous frame of each input source
+	lastButtons []inputButtons
+
+	// used when restarting the game
+	startLevel uint
+	startMap   string
+	// number of levels loaded so far, including restarts
+	levelLoads uint
 
 	timeDelta float64
+	// simulation clock, only advanced by tick()
+	clock time.Duration
+
+	// single random stream used by player and monsters, for reproducible runs
+	random       *rand.Rand
+	randomSource *randomSource
+	seed         int64
+
+	// in deathmatch there are no monsters, players can shoot each other and respawn when killed
+	deathmatch bool
+	// statistics of each completed level are written here as JSON, if set
+	statsLog io.WriteCloser
+	// set when the game is the local copy of a game running on a server
+	client *netClient
 }
 
-func NewGame() (*Game, error) {
-	g := Game{}
-	g.levelNum = 0
-	err := g.loadNextLevel()
+// NewGame creates a game starting from the specified level; startMap, when not empty,
+// is the map file name to use instead of the default one for the level.
+// There is one player for each input source, up to maxPlayers.
+func NewGame(seed int64, startLevel uint, startMap string, deathmatch bool, sources ...inputSource) (*Game, error) {
+	if len(sources) == 0 || len(sources) > maxPlayers {
+		return nil, fmt.Errorf("unsupported number of players: %d", len(sources))
+	}
+	g := Game{deathmatch: deathmatch}
+	g.seedRandom(seed, 0)
+	g.inputSources = sources
+	g.lastButtons = make([]inputButtons, len(sources))
+	if startMap == "" {
+		startMap = levelFileName(startLevel)
+	}
+	g.startLevel, g.startMap = startLevel, startMap
+	err := g.restartGame()
 	if err != nil {
 		return nil, err
 	}
@@ -41,47 +98,234 @@ func NewGame() (*Game, error) {
 	return &g, err
 }
 
+// randomSource counts the values drawn from the game random stream, so that a saved game can
+// restore the stream by drawing the same number of values again.
+type randomSource struct {
+	rand.Source
+	draws uint64
+}
+
+func (s *randomSource) Int63() int64 {
+	s.draws++
+	return s.Source.Int63()
+}
+
+// seedRandom starts the random stream from seed, skipping the first draws values.
+func (g *Game) seedRandom(seed int64, draws uint64) {
+	source := &randomSource{Source: rand.NewSource(seed)}
+	for i := uint64(0); i < draws; i++ {
+		source.Int63()
+	}
+	g.seed, g.randomSource, g.random = seed, source, rand.New(source)
+}
+
+// tick advances the simulation clock by one fixed frame step.
+func (g *Game) tick() {
+	if g.paused {
+		return
+	}
+	g.timeDelta = frameTime.Seconds()
+	g.clock += frameTime
+}
+
+// getDecimals returns the fractional part of the current simulation second.
+func (g *Game) getDecimals() float32 {
+	return float32(g.clock%time.Second) / float32(time.Second)
+}
+
+func (g *Game) numPlayers() int {
+	return len(g.inputSources)
+}
+
 func (g *Game) input() error {
-	return g.level.input()
+	if g.client != nil {
+		return g.client.input()
+	}
+	if g.paused {
+		return nil
+	}
+	for i, source := range g.inputSources {
+		if source == nil {
+			continue
+		}
+		in, err := source.next()
+		if err != nil {
+			return err
+		}
+		// actions are triggered when buttons are pressed, not while held
+		pressed := in.buttons &^ g.lastButtons[i]
+		g.lastButtons[i] = in.buttons
+
+		if g.state == gamePlaying {
+			err = g.level.input(i, in)
+		} else {
+			err = g.action(pressed)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case pressed&inputRestartGame != 0:
+			err = g.restartGame()
+		case pressed&inputRestartLevel != 0 && g.state != gameVictory:
+			err = g.restartLevel()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// action handles the input of players while the game is not being played.
+func (g *Game) action(pressed inputButtons) error {
+	if pressed&(inputUse|inputFire) == 0 {
+		return nil
+	}
+	switch g.state {
+	case gameOver:
+		if !g.level.livesLeft() {
+			return g.restartGame()
+		}
+		return g.restartLevel()
+	case gameLevelComplete:
+		return g.loadNextLevel()
+	}
+	return nil
+}
+
+// setPaused halts or resumes the simulation; network games run on the server, thus cannot be paused.
+func (g *Game) setPaused(paused bool) {
+	if g.client == nil {
+		g.paused = paused
+	}
+}
+
+// setState changes the game state and tells the players about it.
+func (g *Game) setState(state gameState) {
+	g.state = state
+
+	var msg string
+	switch state {
+	case gameOver:
+		msg = "you died - press use to restart the level"
+		if !g.level.livesLeft() {
+			msg = "GAME OVER - press use to restart the game"
+		}
+	case gameLevelComplete:
+		msg = "level complete - press use to continue"
+	case gameVictory:
+		msg = "you have completed the last level!"
+	default:
+		return
+	}
+	fmt.Println(msg)
+	if Window != nil {
+		Window.SetTitle("WolfenGo - " + msg)
+	}
 }
 
 func (g *Game) update() error {
-	if g.isRunning {
+	if g.client != nil {
+		return g.client.update()
+	}
+	if g.state == gamePlaying && !g.paused {
 		return g.level.update()
 	}
 	return nil
 }
 
+// render draws the level, which stays still when the game is not being played.
 func (g *Game) render() {
-	if g.isRunning {
-		g.level.render()
-	}
+	g.level.render()
 }
 
-func (g *Game) Camera() *Camera {
-	return g.level.player.camera
+func (g *Game) nextLevelFileName() string {
+	if g.level.level.Next != "" {
+		// map header overrides the default level sequence
+		return g.level.level.Next
+	}
+	return levelFileName(g.levelNum + 1)
 }
 
-func (g *Game) loadNextLevel() error {
-	var err error
-	g.levelNum++
-	g.level, err = g.NewLevel(g.levelNum)
+// completeLevel is called when a player reaches the exit: the level statistics are tallied
+// and the game is won when there is no next level.
+func (g *Game) completeLevel() error {
+	err := g.tally()
 	if err != nil {
 		return err
 	}
 
-	g.isRunning = true
-
+	_, err = os.Stat("./maps/" + g.nextLevelFileName())
+	if os.IsNotExist(err) {
+		g.setState(gameVictory)
+		return nil
+	}
+	g.setState(gameLevelComplete)
 	return nil
 }
 
-func (g *Game) UnlockMouse() {
-	g.mouseLocked = false
+func (g *Game) loadNextLevel() error {
+	return g.loadLevel(g.nextLevelFileName(), g.levelNum+1, g.level)
 }
 
-func (g *Game) LockMouse() {
-	x, y := Window.GetCursorPos()
-	g.oldPosition = Vector2f{float32(x), float32(y)}
+// restartLevel loads again the current level, with players starting anew.
+func (g *Game) restartLevel() error {
+	return g.loadLevel(g.mapName, g.levelNum, g.level)
+}
 
-	g.mouseLocked = true
+// restartGame loads the level the game started from, with players starting anew.
+func (g *Game) restartGame() error {
+	return g.loadLevel(g.startMap, g.startLevel, nil)
+}
+
+// loadLevel loads the specified map as level number levelNum; players keep their lives and score
+// from the previous level, if any. The game is left untouched if the map cannot be loaded.
+func (g *Game) loadLevel(fileName string, levelNum uint, previous *Level) error {
+	level, err := g.NewLevel(fileName)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		for i, p := range level.players {
+			p.lives, p.score = previous.players[i].lives, previous.players[i].score
+		}
+	}
+	g.levelNum = levelNum
+	g.level = level
+	g.mapName = fileName
+	g.levelLoads++
+
+	if g.level.level.Title != "" {
+		fmt.Printf("level %d: %s\n", g.levelNum, g.level.level.Title)
+		if Window != nil {
+			Window.SetTitle("WolfenGo - " + g.level.level.Title)
+		}
+	}
+
+	g.setState(gamePlaying)
+
+	return nil
+}
+
+// shutdown releases the input sources, e.g. flushing a demo being recorded.
+func (g *Game) shutdown() error {
+	var err error
+	if g.client != nil {
+		err = g.client.Close()
+	}
+	if g.statsLog != nil {
+		if cerr := g.statsLog.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, source := range g.inputSources {
+		if c, ok := source.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
 }
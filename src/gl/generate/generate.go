@@ -91,6 +91,7 @@ var (
 	GetString                = gl.GetString
 	DebugMessageCallback     = gl.DebugMessageCallback
 	Enable                   = gl.Enable
+	Disable                  = gl.Disable
 	ClearColor               = gl.ClearColor
 	FrontFace                = gl.FrontFace
 	CullFace                 = gl.CullFace
@@ -128,6 +129,7 @@ var (
 	TexImage2D               = gl.TexImage2D
 	GenVertexArrays          = gl.GenVertexArrays
 	BindVertexArray          = gl.BindVertexArray
+	Viewport                 = gl.Viewport
 )
 `, glVer, major, minor)
 }
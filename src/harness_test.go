@@ -0,0 +1,229 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+const testSeed = 1547332021
+
+func TestMain(m *testing.M) {
+	// maps and resources are loaded relative to the repository root
+	err := os.Chdir("..")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	headless = true
+	err = loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+type scriptStep struct {
+	in    inputFrame
+	ticks int
+}
+
+// script is an input source replaying scripted steps; when all steps
+// have been consumed no further buttons are pressed.
+type script struct {
+	steps []scriptStep
+}
+
+func (s *script) next() (inputFrame, error) {
+	for len(s.steps) > 0 {
+		step := &s.steps[0]
+		if step.ticks > 0 {
+			step.ticks--
+			return step.in, nil
+		}
+		s.steps = s.steps[1:]
+	}
+	return inputFrame{}, nil
+}
+
+func (s *script) pending() int {
+	var ticks int
+	for _, step := range s.steps {
+		ticks += step.ticks
+	}
+	return ticks
+}
+
+func (s *script) hold(buttons inputButtons, ticks int) *script {
+	s.steps = append(s.steps, scriptStep{inputFrame{buttons: buttons}, ticks})
+	return s
+}
+
+func (s *script) idle(ticks int) *script {
+	return s.hold(0, ticks)
+}
+
+func (s *script) look(delta Vector2f) *script {
+	s.steps = append(s.steps, scriptStep{inputFrame{mouseDelta: delta}, 1})
+	return s
+}
+
+func (s *script) selectWeapon(slot uint8) *script {
+	s.steps = append(s.steps, scriptStep{inputFrame{weapon: slot}, 1})
+	return s
+}
+
+// harness drives a headless game with scripted input.
+type harness struct {
+	t *testing.T
+	g *Game
+	// input of the first player
+	script *script
+	// input of the second player, in co-op mode
+	script2 *script
+}
+
+func newHarness(t *testing.T, levelNum uint) *harness {
+	h := &harness{t: t, script: &script{}}
+	var err error
+	h.g, err = NewGame(testSeed, levelNum, "", false, h.script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func newCoopHarness(t *testing.T, levelNum uint) *harness {
+	return newTwoPlayersHarness(t, levelNum, false)
+}
+
+func newDeathmatchHarness(t *testing.T, levelNum uint) *harness {
+	return newTwoPlayersHarness(t, levelNum, true)
+}
+
+func newTwoPlayersHarness(t *testing.T, levelNum uint, deathmatch bool) *harness {
+	h := &harness{t: t, script: &script{}, script2: &script{}}
+	var err error
+	h.g, err = NewGame(testSeed, levelNum, "", deathmatch, h.script, h.script2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func (h *harness) level() *Level {
+	return h.g.level
+}
+
+func (h *harness) player() *Player {
+	return h.g.level.players[0]
+}
+
+func (h *harness) player2() *Player {
+	return h.g.level.players[1]
+}
+
+// removeMonsters removes all monsters from the level, for scenarios that must not be disturbed.
+func (h *harness) removeMonsters() {
+	h.g.level.monsters = nil
+}
+
+// removePickups removes all pickups from the level, so that scenarios can place their own.
+func (h *harness) removePickups() {
+	h.g.level.pickups = nil
+}
+
+// placePlayer moves the player to the center of the specified map cell, looking towards forward.
+func (h *harness) placePlayer(x, y int, forward Vector3f) {
+	h.placePlayerAt(float32(x)+0.5, float32(y)+0.5, forward)
+}
+
+// placePlayerAt moves the player to the specified world coordinates, looking towards forward.
+func (h *harness) placePlayerAt(x, z float32, forward Vector3f) {
+	movePlayer(h.player(), x, z, forward)
+}
+
+func movePlayer(p *Player, x, z float32, forward Vector3f) {
+	c := p.camera
+	c.pos.X, c.pos.Z = x, z
+	c.forward = forward.normalised()
+	c.up = yAxis
+}
+
+// run advances the game by the specified number of ticks.
+func (h *harness) run(ticks int) {
+	h.t.Helper()
+	for i := 0; i < ticks; i++ {
+		h.g.tick()
+		err := h.g.input()
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		err = h.g.update()
+		if err != nil {
+			h.t.Fatal(err)
+		}
+	}
+}
+
+// runScript advances the game until all scripted input has been consumed.
+func (h *harness) runScript() {
+	h.t.Helper()
+	ticks := h.script.pending()
+	if h.script2 != nil && h.script2.pending() > ticks {
+		ticks = h.script2.pending()
+	}
+	h.run(ticks)
+}
+
+// runFor advances the game by the specified amount of game time.
+func (h *harness) runFor(d time.Duration) {
+	h.t.Helper()
+	h.run(int(d / frameTime))
+}
+
+func (h *harness) monsterAt(x, y int) *Monster {
+	h.t.Helper()
+	pos := Vector2f{float32(x) + 0.5, float32(y) + 0.5}
+	for _, m := range h.g.level.monsters {
+		if (Vector2f{m.transform.translation.X, m.transform.translation.Z}).sub(pos).length() < 0.5 {
+			return m
+		}
+	}
+	h.t.Fatalf("no monster at %d,%d", x, y)
+	return nil
+}
+
+func (h *harness) doorAt(x, y int) *Door {
+	h.t.Helper()
+	for _, d := range h.g.level.doors {
+		p := d.closePosition
+		if int(p.X) == x && int(p.Z) == y {
+			return d
+		}
+	}
+	h.t.Fatalf("no door at %d,%d", x, y)
+	return nil
+}
@@ -0,0 +1,349 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/gdm85/wolfengo/src/gl"
+)
+
+const (
+	// layout of the status bar is designed for the width of the window, and scaled with the viewport
+	hudDesignWidth = windowWidth
+	hudHeight      = 40
+
+	faceWidth, faceHeight = 24, 28
+	faceScale             = 1.25
+	// faces from full health to near death
+	numFaceLevels = 5
+	// the face grimaces for this long after each damage
+	faceOuchTime = 500 * time.Millisecond
+	// the face looks around, changing direction with this interval
+	faceGlanceTime = 1500 * time.Millisecond
+)
+
+const (
+	glanceCenter = iota
+	glanceLeft
+	glanceRight
+	numGlances
+)
+
+// glanceSequence is repeated by the face while nothing happens.
+var glanceSequence = []int{glanceCenter, glanceLeft, glanceCenter, glanceRight}
+
+var (
+	hudBackground = Vector3f{0, 0, 0.45}
+	hudLabelColor = Vector3f{0.8, 0.8, 0.8}
+	hudValueColor = Vector3f{1, 1, 1}
+	hudTitleColor = Vector3f{1, 0.2, 0.1}
+)
+
+// hud is the 2D overlay drawn after the level: a status bar in the style of the original game
+// with level, score, lives, face, health and ammo, plus messages while the game is not being played.
+type hud struct {
+	font       *bitmapFont
+	quad       Mesh
+	background *Material
+	faces      [numFaceLevels][numGlances]*Material
+	ouchFaces  [numFaceLevels]*Material
+	deadFace   *Material
+
+	// darkens the level behind menus
+	shade *Material
+}
+
+var _hud hud
+
+// newQuad creates a unit square mesh in the XY plane, mapping the specified part of a texture.
+func newQuad(u0, v0, u1, v1 float32) Mesh {
+	vertices := []*Vertex{
+		&Vertex{Vector3f{0, 0, 0}, Vector2f{u0, v1}, Vector3f{}},
+		&Vertex{Vector3f{0, 1, 0}, Vector2f{u0, v0}, Vector3f{}},
+		&Vertex{Vector3f{1, 1, 0}, Vector2f{u1, v0}, Vector3f{}},
+		&Vertex{Vector3f{1, 0, 0}, Vector2f{u1, v1}, Vector3f{}},
+	}
+	indices := []int32{0, 1, 2, 0, 2, 3}
+
+	return NewMesh(vertices, indices, false)
+}
+
+func (h *hud) initHUD() {
+	h.font = newBitmapFont()
+	h.quad = newQuad(0, 0, 1, 1)
+
+	white := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	white.SetNRGBA(0, 0, color.NRGBA{255, 255, 255, 255})
+	h.background = NewMaterial(NewTextureFromImage(white))
+	h.background.color = hudBackground
+
+	black := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	black.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, menuShadeAlpha})
+	h.shade = NewMaterial(NewTextureFromImage(black))
+
+	for level := 0; level < numFaceLevels; level++ {
+		for glance := 0; glance < numGlances; glance++ {
+			h.faces[level][glance] = NewMaterial(NewTextureFromImage(faceImage(face{level: level, glance: glance})))
+		}
+		h.ouchFaces[level] = NewMaterial(NewTextureFromImage(faceImage(face{level: level, ouch: true})))
+	}
+	h.deadFace = NewMaterial(NewTextureFromImage(faceImage(face{dead: true})))
+}
+
+// face is the look of the status bar face.
+type face struct {
+	// from 0 (full health) to numFaceLevels-1
+	level      int
+	glance     int
+	ouch, dead bool
+}
+
+// playerFace returns the face reflecting the health of the player and recent damage.
+func playerFace(p *Player) face {
+	if p.dead() {
+		return face{dead: true}
+	}
+
+	f := face{level: (defaultPlayer.maxHealth - p.health) * numFaceLevels / defaultPlayer.maxHealth}
+	if f.level < 0 {
+		f.level = 0
+	} else if f.level >= numFaceLevels {
+		f.level = numFaceLevels - 1
+	}
+	if p.hasDamage && p.game.clock-p.lastDamage < faceOuchTime {
+		f.ouch = true
+		return f
+	}
+	f.glance = glanceSequence[int(p.game.clock/faceGlanceTime)%len(glanceSequence)]
+	return f
+}
+
+func (h *hud) faceMaterial(f face) *Material {
+	switch {
+	case f.dead:
+		return h.deadFace
+	case f.ouch:
+		return h.ouchFaces[f.level]
+	}
+	return h.faces[f.level][f.glance]
+}
+
+var (
+	faceSkin     = color.NRGBA{228, 170, 128, 255}
+	faceDeadSkin = color.NRGBA{170, 170, 160, 255}
+	faceShadow   = color.NRGBA{196, 136, 100, 255}
+	faceHair     = color.NRGBA{216, 176, 72, 255}
+	faceEye      = color.NRGBA{255, 255, 255, 255}
+	facePupil    = color.NRGBA{40, 80, 200, 255}
+	faceMouth    = color.NRGBA{120, 40, 40, 255}
+	faceDark     = color.NRGBA{40, 0, 0, 255}
+	faceBlood    = color.NRGBA{176, 0, 0, 255}
+)
+
+// faceBloodSpots are filled in order as the health gets lower.
+var faceBloodSpots = []image.Point{
+	{5, 16}, {6, 17}, {18, 9}, {17, 18}, {18, 19}, {8, 24},
+	{15, 24}, {4, 12}, {19, 14}, {10, 5}, {14, 25}, {6, 20},
+	{20, 16}, {11, 7}, {5, 9}, {16, 22},
+}
+
+// faceImage draws the face procedurally, as there are no face sprites among the game resources.
+func faceImage(f face) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, faceWidth, faceHeight))
+	fill := func(x0, y0, x1, y1 int, c color.NRGBA) {
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+
+	// head, with the hair on top
+	skin := faceSkin
+	if f.dead {
+		skin = faceDeadSkin
+	}
+	const cx, cy, rx, ry = 12, 15, 10, 12.5
+	for y := 0; y < faceHeight; y++ {
+		for x := 0; x < faceWidth; x++ {
+			dx, dy := (float32(x)+0.5-cx)/rx, (float32(y)+0.5-cy)/ry
+			if dx*dx+dy*dy > 1 {
+				continue
+			}
+			if y < 8 {
+				img.SetNRGBA(x, y, faceHair)
+			} else {
+				img.SetNRGBA(x, y, skin)
+			}
+		}
+	}
+	fill(6, 10, 9, 10, faceHair)
+	fill(14, 10, 17, 10, faceHair)
+	fill(11, 15, 12, 17, faceShadow)
+
+	// eyes
+	switch {
+	case f.dead:
+		for i := 0; i < 4; i++ {
+			for _, x := range []int{6, 14} {
+				img.SetNRGBA(x+i, 11+i, faceDark)
+				img.SetNRGBA(x+3-i, 11+i, faceDark)
+			}
+		}
+	case f.ouch:
+		fill(6, 11, 9, 13, faceEye)
+		fill(14, 11, 17, 13, faceEye)
+		img.SetNRGBA(7, 12, facePupil)
+		img.SetNRGBA(16, 12, facePupil)
+	default:
+		fill(6, 12, 9, 13, faceEye)
+		fill(14, 12, 17, 13, faceEye)
+		offset := 1
+		switch f.glance {
+		case glanceLeft:
+			offset = 0
+		case glanceRight:
+			offset = 2
+		}
+		fill(6+offset, 12, 7+offset, 13, facePupil)
+		fill(14+offset, 12, 15+offset, 13, facePupil)
+	}
+
+	// mouth
+	switch {
+	case f.ouch:
+		fill(10, 20, 13, 23, faceDark)
+	case f.dead:
+		fill(9, 21, 14, 21, faceDark)
+	default:
+		fill(9, 21, 14, 21, faceMouth)
+		if f.level == 0 {
+			// smile
+			img.SetNRGBA(8, 20, faceMouth)
+			img.SetNRGBA(15, 20, faceMouth)
+		} else if f.level >= 3 {
+			img.SetNRGBA(8, 22, faceMouth)
+			img.SetNRGBA(15, 22, faceMouth)
+		}
+	}
+
+	spots := f.level * 4
+	if f.dead || spots > len(faceBloodSpots) {
+		spots = len(faceBloodSpots)
+	}
+	for _, p := range faceBloodSpots[:spots] {
+		img.SetNRGBA(p.X, p.Y, faceBlood)
+	}
+
+	return img
+}
+
+// stateMessage returns the message shown in the middle of the view, if any.
+func stateMessage(p *Player) (title, hint string) {
+	g := p.game
+	switch g.state {
+	case gameOver:
+		if g.level.livesLeft() {
+			return "you died", "press use to restart the level"
+		}
+		return "game over", "press use to restart the game"
+	case gameLevelComplete:
+		return "level complete", "press use to continue"
+	case gameVictory:
+		return "victory!", "you have completed the last level"
+	}
+	if p.dead() && g.deathmatch {
+		return "fragged", "respawning..."
+	}
+	return "", ""
+}
+
+// render draws the overlay on the view of the player.
+func (h *hud) render(p *Player) {
+	c := p.camera
+	s := p.game.level.shader
+	s.tint = Vector3f{1, 1, 1}
+
+	gl.Disable(gl.DEPTH_TEST)
+	defer gl.Enable(gl.DEPTH_TEST)
+
+	k := c.width / hudDesignWidth
+	h.drawQuad(s, c, 0, 0, c.width, hudHeight*k, h.background)
+
+	h.drawField(s, c, 60*k, "level", fmt.Sprint(p.game.levelNum))
+	h.drawField(s, c, 190*k, "score", fmt.Sprint(p.score))
+	if p.game.deathmatch {
+		h.drawField(s, c, 320*k, "frags", fmt.Sprint(p.frags))
+	} else {
+		lives := p.lives
+		if lives < 0 {
+			lives = 0
+		}
+		h.drawField(s, c, 320*k, "lives", fmt.Sprint(lives))
+	}
+
+	faceW, faceH := faceWidth*faceScale*k, faceHeight*faceScale*k
+	h.drawQuad(s, c, (c.width-faceW)/2, (hudHeight*k-faceH)/2, faceW, faceH, h.faceMaterial(playerFace(p)))
+
+	health := p.health
+	if health < 0 {
+		health = 0
+	}
+	h.drawField(s, c, 500*k, "health", fmt.Sprintf("%d%%", health))
+	def := &weaponDefs[p.weapon]
+	h.drawField(s, c, 660*k, def.name, fmt.Sprint(p.ammo[def.ammo]))
+
+	title, hint := stateMessage(p)
+	if title == "" {
+		return
+	}
+	y := c.height * 3 / 4
+	h.drawCentered(s, c, c.width/2, y, 4*k, title, hudTitleColor)
+	y -= 16 * k
+	h.drawCentered(s, c, c.width/2, y, 2*k, hint, hudValueColor)
+	if t := p.game.level.tally; t != nil && (p.game.state == gameLevelComplete || p.game.state == gameVictory) {
+		for _, line := range t.tallyLines() {
+			y -= 24 * k
+			h.drawCentered(s, c, c.width/2, y, 3*k, line, hudValueColor)
+		}
+	}
+}
+
+// drawField draws a label with its value below, centered on x.
+func (h *hud) drawField(s *Shader, c *Camera, x float32, label, value string) {
+	k := c.width / hudDesignWidth
+	h.drawCentered(s, c, x, (hudHeight-10)*k, k, label, hudLabelColor)
+	h.drawCentered(s, c, x, 5*k, 3*k, value, hudValueColor)
+}
+
+func (h *hud) drawCentered(s *Shader, c *Camera, x, y, scale float32, text string, color Vector3f) {
+	h.font.drawText(s, c.width, c.height, x-textWidth(text, scale)/2, y, scale, text, color)
+}
+
+func (h *hud) drawQuad(s *Shader, c *Camera, x, y, width, height float32, m *Material) {
+	var t Transform
+	t.translation = Vector3f{x, y, 0}
+	t.scale = Vector3f{width, height, 1}
+	s.updateUniforms(t.getOrthoTransformation(c.width, c.height), m)
+	h.quad.draw()
+}
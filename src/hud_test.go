@@ -0,0 +1,144 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrthographicProjection(t *testing.T) {
+	var tr Transform
+	tr.scale = Vector3f{1, 1, 1}
+	m := tr.getOrthoTransformation(800, 600)
+
+	for _, tc := range []struct {
+		x, y, clipX, clipY float32
+	}{
+		{0, 0, -1, -1},
+		{800, 600, 1, 1},
+		{400, 300, 0, 0},
+		{800, 0, 1, -1},
+	} {
+		clipX := m[0][0]*tc.x + m[0][1]*tc.y + m[0][3]
+		clipY := m[1][0]*tc.x + m[1][1]*tc.y + m[1][3]
+		if clipX != tc.clipX || clipY != tc.clipY {
+			t.Errorf("%v,%v projected to %v,%v instead of %v,%v", tc.x, tc.y, clipX, clipY, tc.clipX, tc.clipY)
+		}
+	}
+}
+
+func TestFontGlyphs(t *testing.T) {
+	for r, glyph := range fontGlyphs {
+		rows := strings.Fields(glyph)
+		if len(rows) != glyphHeight {
+			t.Errorf("glyph %q has %d rows", r, len(rows))
+		}
+		for _, row := range rows {
+			if len(row) != glyphWidth || strings.Trim(row, ".#") != "" {
+				t.Errorf("glyph %q has invalid row %q", r, row)
+			}
+		}
+	}
+
+	if w := textWidth("100%", 3); w != (4*glyphCellWidth-1)*3 {
+		t.Errorf("unexpected text width %v", w)
+	}
+}
+
+func TestPlayerFace(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	p := h.player()
+
+	if f := playerFace(p); f.level != 0 || f.ouch || f.dead {
+		t.Errorf("unexpected face at full health: %+v", f)
+	}
+
+	p.damage(50)
+	if f := playerFace(p); f.level != 2 || !f.ouch {
+		t.Errorf("unexpected face after damage: %+v", f)
+	}
+	h.runFor(faceOuchTime)
+	if f := playerFace(p); f.level != 2 || f.ouch {
+		t.Errorf("face should stop grimacing: %+v", f)
+	}
+
+	glances := make(map[int]bool)
+	for i := 0; i < len(glanceSequence); i++ {
+		glances[playerFace(p).glance] = true
+		h.runFor(faceGlanceTime)
+	}
+	if len(glances) != numGlances {
+		t.Errorf("face should look around, but only had glances %v", glances)
+	}
+
+	p.damage(49)
+	if f := playerFace(p); f.level != numFaceLevels-1 {
+		t.Errorf("unexpected face near death: %+v", f)
+	}
+	p.damage(1)
+	if f := playerFace(p); !f.dead {
+		t.Errorf("unexpected face of dead player: %+v", f)
+	}
+}
+
+func TestScoreAndLives(t *testing.T) {
+	h := newHarness(t, 1)
+
+	monster := h.monsterAt(13, 28)
+	h.placePlayerAt(13.6, 24.5, towardsPlusZ)
+	for i := 0; i < 375 && monster.alive(); i++ {
+		h.script.hold(inputFire, 1).idle(1)
+		h.runScript()
+	}
+	if h.player().score != killScore {
+		t.Fatalf("expected score %d but got %d", killScore, h.player().score)
+	}
+
+	for lives := startLives - 1; lives >= 0; lives-- {
+		h.player().damage(defaultPlayer.maxHealth)
+		if h.player().lives != lives || !h.level().livesLeft() {
+			t.Fatalf("expected %d lives but got %d", lives, h.player().lives)
+		}
+		if title, _ := stateMessage(h.player()); title != "you died" {
+			t.Errorf("unexpected message %q", title)
+		}
+
+		h.script.idle(1).hold(inputUse, 1)
+		h.runScript()
+		if h.g.state != gamePlaying || h.player().lives != lives || h.player().score != killScore {
+			t.Fatalf("lives and score should be kept restarting the level, but got %d and %d", h.player().lives, h.player().score)
+		}
+	}
+
+	h.player().damage(defaultPlayer.maxHealth)
+	if h.level().livesLeft() {
+		t.Fatal("no lives should be left")
+	}
+	if title, _ := stateMessage(h.player()); title != "game over" {
+		t.Errorf("unexpected message %q", title)
+	}
+
+	h.script.idle(1).hold(inputUse, 1)
+	h.runScript()
+	if h.g.state != gamePlaying || h.player().lives != startLives || h.player().score != 0 {
+		t.Errorf("game should restart with %d lives and no score, but got %d and %d", startLives, h.player().lives, h.player().score)
+	}
+}
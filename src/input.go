@@ -0,0 +1,245 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import "github.com/go-gl/glfw/v3.1/glfw"
+
+type inputButtons uint8
+
+const (
+	inputForward inputButtons = 1 << iota
+	inputBack
+	inputLeft
+	inputRight
+	inputUse
+	inputFire
+	inputRestartLevel
+	inputRestartGame
+)
+
+// inputFrame is the player input sampled for a single simulation tick.
+type inputFrame struct {
+	buttons inputButtons
+	// weapon slot selected with the number keys (starting from 1), 0 if none
+	weapon     uint8
+	mouseDelta Vector2f
+	// absolute camera orientation, used instead of the mouse delta by network clients
+	orientation *orientation
+}
+
+type orientation struct {
+	forward, up Vector3f
+}
+
+func (in inputFrame) pressed(b inputButtons) bool {
+	return in.buttons&b != 0
+}
+
+// inputSource provides the player input, one frame per simulation tick.
+type inputSource interface {
+	next() (inputFrame, error)
+}
+
+// keyBindings maps keyboard keys, mouse and gamepad to the player input.
+type keyBindings struct {
+	forward, back, left, right glfw.Key
+	use, fire                  glfw.Key
+	weapons                    [numWeapons]glfw.Key
+	// keyboard turning, for players without mouse; 0 when unbound
+	turnLeft, turnRight glfw.Key
+	// 0 when unbound
+	restartLevel, restartGame glfw.Key
+
+	mouse    bool
+	joystick glfw.Joystick
+}
+
+const (
+	// mouse delta equivalent of turning with keys or gamepad for a tick
+	keyTurnDelta = 3
+	// gamepad axes values below this are ignored
+	joystickDeadZone = 0.3
+)
+
+// firstPlayerBindings are used in single player mode and by the first player in co-op mode;
+// fire is the left mouse button.
+var firstPlayerBindings = keyBindings{
+	forward:      glfw.KeyW,
+	back:         glfw.KeyS,
+	left:         glfw.KeyA,
+	right:        glfw.KeyD,
+	use:          glfw.KeyE,
+	weapons:      [numWeapons]glfw.Key{glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4, glfw.Key5},
+	restartLevel: glfw.KeyF5,
+	restartGame:  glfw.KeyF9,
+	mouse:        true,
+	joystick:     glfw.Joystick1,
+}
+
+// secondPlayerBindings are used by the second player in co-op mode, who can also use a second gamepad.
+var secondPlayerBindings = keyBindings{
+	forward:   glfw.KeyUp,
+	back:      glfw.KeyDown,
+	left:      glfw.KeyComma,
+	right:     glfw.KeyPeriod,
+	turnLeft:  glfw.KeyLeft,
+	turnRight: glfw.KeyRight,
+	use:       glfw.KeyRightShift,
+	fire:      glfw.KeyRightControl,
+	weapons:   [numWeapons]glfw.Key{glfw.KeyKP1, glfw.KeyKP2, glfw.KeyKP3, glfw.KeyKP4, glfw.KeyKP5},
+	joystick:  glfw.Joystick2,
+}
+
+// windowInput reads the input of a player from the GLFW window.
+// Mouse lock is handled here, as it is not part of the gameplay; the mouse is released by menus.
+type windowInput struct {
+	bindings    *keyBindings
+	oldPosition Vector2f
+	mouseLocked bool
+}
+
+func (wi *windowInput) pressed(key glfw.Key) bool {
+	return key != 0 && Window.GetKey(key) == glfw.Press
+}
+
+func (wi *windowInput) next() (inputFrame, error) {
+	var in inputFrame
+	b := wi.bindings
+
+	if wi.pressed(b.use) {
+		in.buttons |= inputUse
+	}
+	if wi.pressed(b.fire) {
+		in.buttons |= inputFire
+	}
+	if wi.pressed(b.restartLevel) {
+		in.buttons |= inputRestartLevel
+	}
+	if wi.pressed(b.restartGame) {
+		in.buttons |= inputRestartGame
+	}
+
+	if b.mouse {
+		if Window.GetInputMode(glfw.CursorMode) != glfw.CursorDisabled {
+			wi.mouseLocked = false
+		}
+
+		// wait for left mouse click to lock the camera to the mouse
+		if Window.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press {
+			if !wi.mouseLocked {
+				Window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+				wi.lockMouse()
+			} else {
+				in.buttons |= inputFire
+			}
+		}
+	}
+
+	if wi.pressed(b.forward) {
+		in.buttons |= inputForward
+	}
+	if wi.pressed(b.back) {
+		in.buttons |= inputBack
+	}
+	if wi.pressed(b.left) {
+		in.buttons |= inputLeft
+	}
+	if wi.pressed(b.right) {
+		in.buttons |= inputRight
+	}
+	if wi.pressed(b.turnLeft) {
+		in.mouseDelta.X -= keyTurnDelta
+	}
+	if wi.pressed(b.turnRight) {
+		in.mouseDelta.X += keyTurnDelta
+	}
+	for i, key := range b.weapons {
+		if wi.pressed(key) {
+			in.weapon = uint8(i + 1)
+		}
+	}
+	if b.mouse && wi.mouseLocked {
+		x, y := Window.GetCursorPos()
+		newPosition := Vector2f{float32(x), float32(y)}
+		delta := newPosition.sub(wi.oldPosition).mulf(float32(options.mouseSpeed) / defaultMouseSpeed)
+		if options.invertMouse {
+			delta.Y = -delta.Y
+		}
+		in.mouseDelta = in.mouseDelta.add(delta)
+		wi.oldPosition = newPosition
+	}
+
+	readJoystick(b.joystick, &in)
+
+	return in, nil
+}
+
+// readJoystick adds the gamepad input, if connected: left stick moves, right stick
+// (third axis) turns, first button fires and second button opens doors.
+func readJoystick(joy glfw.Joystick, in *inputFrame) {
+	if !glfw.JoystickPresent(joy) {
+		return
+	}
+
+	axes := glfw.GetJoystickAxes(joy)
+	if len(axes) >= 2 {
+		if axes[0] < -joystickDeadZone {
+			in.buttons |= inputLeft
+		} else if axes[0] > joystickDeadZone {
+			in.buttons |= inputRight
+		}
+		if axes[1] < -joystickDeadZone {
+			in.buttons |= inputForward
+		} else if axes[1] > joystickDeadZone {
+			in.buttons |= inputBack
+		}
+	}
+	if len(axes) >= 3 && (axes[2] < -joystickDeadZone || axes[2] > joystickDeadZone) {
+		in.mouseDelta.X += axes[2] * keyTurnDelta
+	}
+
+	buttons := glfw.GetJoystickButtons(joy)
+	if len(buttons) >= 1 && glfw.Action(buttons[0]) == glfw.Press {
+		in.buttons |= inputFire
+	}
+	if len(buttons) >= 2 && glfw.Action(buttons[1]) == glfw.Press {
+		in.buttons |= inputUse
+	}
+}
+
+// quickKeys reads the quicksave and quickload keys, which are not part of the player input
+// thus are neither recorded in demos nor sent over the network.
+type quickKeys struct {
+	saveHeld, loadHeld bool
+}
+
+// poll returns which actions have been requested since the previous call.
+func (qk *quickKeys) poll() (save, load bool) {
+	saveKey, loadKey := Window.GetKey(glfw.KeyF6) == glfw.Press, Window.GetKey(glfw.KeyF7) == glfw.Press
+	save, load = saveKey && !qk.saveHeld, loadKey && !qk.loadHeld
+	qk.saveHeld, qk.loadHeld = saveKey, loadKey
+	return
+}
+
+func (wi *windowInput) lockMouse() {
+	x, y := Window.GetCursorPos()
+	wi.oldPosition = Vector2f{float32(x), float32(y)}
+
+	wi.mouseLocked = true
+}
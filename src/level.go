@@ -21,8 +21,10 @@ package main
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/gdm85/wolfengo/src/gl"
+	"github.com/gdm85/wolfengo/src/wolfmap"
 )
 
 const (
@@ -37,18 +39,31 @@ const (
 var numTextures = uint32(math.Pow(2, numTexExp))
 
 type Level struct {
-	mesh                               Mesh
-	level                              *Map
-	shader                             *Shader
-	material                           *Material
-	transform                          *Transform
-	player                             *Player
+	mesh      Mesh
+	level     *wolfmap.Map
+	shader    *Shader
+	material  *Material
+	transform *Transform
+	// the first player is the one starting from 'A', the second one (co-op mode only) from 'B'
+	players                            []*Player
 	doors                              []*Door
 	monsters                           []*Monster
-	medkits                            []*Medkit
-	medkitsToRemove                    []*Medkit
+	pickups                            []*Pickup
+	pickupsToRemove                    []*Pickup
 	exitPoints                         []*Vector3f
+	secrets                            []*secret
 	collisionPosStart, collisionPosEnd []*Vector2f
+	// routes for monsters
+	paths *pathfinder
+
+	// statistics, see stats()
+	kills, totalKills int
+	items, totalItems int
+	secretsFound      int
+	// game time spent playing the level
+	elapsed time.Duration
+	// set when the level is completed
+	tally *levelStats
 
 	game *Game // parent game
 }
@@ -60,6 +75,12 @@ var (
 
 func getBasicShader() (*Shader, error) {
 	if _basicShader == nil {
+		if headless {
+			// a shader without uniforms will not update anything
+			collectionTexture = &Texture{}
+			_basicShader = &Shader{}
+			return _basicShader, nil
+		}
 		var err error
 		collectionTexture, err = NewTexture("WolfCollection.png")
 		if err != nil {
@@ -97,20 +118,21 @@ func getBasicShader() (*Shader, error) {
 	return _basicShader, nil
 }
 
-func (g *Game) NewLevel(levelNum uint) (*Level, error) {
+func levelFileName(levelNum uint) string {
+	if debugLevelTest {
+		return "levelTest.map"
+	}
+	return fmt.Sprintf("level%d.map", levelNum)
+}
+
+func (g *Game) NewLevel(fileName string) (*Level, error) {
 	l := &Level{game: g}
+	l.players = make([]*Player, g.numPlayers())
 
 	l.transform = l.game.NewTransform()
 
-	var fileName string
-	if debugLevelTest {
-		fileName = "levelTest.map"
-	} else {
-		fileName = fmt.Sprintf("level%d.map", levelNum)
-	}
-
 	var err error
-	l.level, err = NewMap(fileName)
+	l.level, err = wolfmap.Load("./maps/" + fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -126,11 +148,24 @@ func (g *Game) NewLevel(levelNum uint) (*Level, error) {
 	if err != nil {
 		return nil, err
 	}
+	l.totalKills, l.totalItems = len(l.monsters), len(l.pickups)
+	l.paths = newPathfinder(l.level)
 
 	// some validation
-	if l.player == nil {
+	if l.players[0] == nil {
 		return nil, fmt.Errorf("invalid generated level: no player set")
 	}
+	for i, p := range l.players {
+		if p == nil {
+			// maps without a second player start have both players start together
+			l.players[i] = l.game.NewPlayer(l.players[0].camera.pos, defaultPlayer.mesh)
+		}
+	}
+	if l.splitScreen() {
+		for _, p := range l.players {
+			p.camera.height /= float32(len(l.players))
+		}
+	}
 
 	return l, nil
 }
@@ -145,10 +180,7 @@ func (l *Level) openDoors(position Vector3f, tryExitLevel bool) error {
 	if tryExitLevel {
 		for _, exitPoint := range l.exitPoints {
 			if exitPoint.sub(position).length() < openDistance {
-				err := l.game.loadNextLevel()
-				if err != nil {
-					return err
-				}
+				return l.game.completeLevel()
 			}
 		}
 	}
@@ -156,23 +188,99 @@ func (l *Level) openDoors(position Vector3f, tryExitLevel bool) error {
 	return nil
 }
 
-func (l *Level) damagePlayer(amt int) {
-	l.player.damage(amt)
+// input applies the input of the specified player; dead players ignore it.
+func (l *Level) input(player int, in inputFrame) error {
+	p := l.players[player]
+	if p.dead() {
+		return nil
+	}
+	return p.input(in)
+}
+
+func (l *Level) allPlayersDead() bool {
+	for _, p := range l.players {
+		if !p.dead() {
+			return false
+		}
+	}
+	return true
+}
+
+// livesLeft is true when at least one player can play the level again.
+func (l *Level) livesLeft() bool {
+	for _, p := range l.players {
+		if p.lives >= 0 {
+			return true
+		}
+	}
+	return false
 }
 
-func (l *Level) input() error {
-	return l.player.input()
+// targetPlayer returns the nearest living player visible from position or, if none is
+// visible, the nearest living one; it returns nil when all players are dead.
+func (l *Level) targetPlayer(position Vector3f) *Player {
+	var nearest, nearestVisible *Player
+	var distance, visibleDistance float32
+
+	for _, p := range l.players {
+		if p.dead() {
+			continue
+		}
+		d := p.camera.pos.sub(position).length()
+		if nearest == nil || d < distance {
+			nearest, distance = p, d
+		}
+		// with a single player there is no choice to make
+		if len(l.players) > 1 && (nearestVisible == nil || d < visibleDistance) && l.lineOfSight(position, p.camera.pos) {
+			nearestVisible, visibleDistance = p, d
+		}
+	}
+
+	if nearestVisible != nil {
+		return nearestVisible
+	}
+	return nearest
+}
+
+// lineOfSight returns true when no wall or door stands between from and to.
+func (l *Level) lineOfSight(from, to Vector3f) bool {
+	return l.checkIntersections(Vector2f{from.X, from.Z}, Vector2f{to.X, to.Z}, nil) == nil
+}
+
+// nearestPlayer returns the nearest living player within maxDistance of position, if any.
+func (l *Level) nearestPlayer(position Vector3f, maxDistance float32) *Player {
+	var nearest *Player
+	for _, p := range l.players {
+		if p.dead() {
+			continue
+		}
+		d := p.camera.pos.sub(position).length()
+		if d < maxDistance {
+			nearest, maxDistance = p, d
+		}
+	}
+	return nearest
 }
 
 func (l *Level) update() error {
+	l.elapsed += frameTime
+
 	for _, door := range l.doors {
 		door.update()
 	}
 
-	l.player.update()
+	l.findSecrets()
 
-	for _, medkit := range l.medkits {
-		medkit.update()
+	for _, p := range l.players {
+		if !p.dead() {
+			p.update()
+		} else if l.game.deathmatch && l.game.clock >= p.respawnAt {
+			p.respawn()
+		}
+	}
+
+	for _, pickup := range l.pickups {
+		pickup.update()
 	}
 
 	for _, monster := range l.monsters {
@@ -182,49 +290,78 @@ func (l *Level) update() error {
 		}
 	}
 
-	if len(l.medkitsToRemove) > 0 {
-		newMedkits := make([]*Medkit, 0, len(l.medkits)-len(l.medkitsToRemove))
-		for _, m := range l.medkits {
+	if len(l.pickupsToRemove) > 0 {
+		newPickups := make([]*Pickup, 0, len(l.pickups))
+		for _, p := range l.pickups {
 			removed := false
-			for _, r := range l.medkitsToRemove {
-				if m == r {
+			for _, r := range l.pickupsToRemove {
+				if p == r {
 					removed = true
 					break
 				}
 			}
 			if !removed {
-				newMedkits = append(newMedkits, m)
+				newPickups = append(newPickups, p)
 			}
 		}
-		l.medkits = newMedkits
+		l.pickups = newPickups
+		l.pickupsToRemove = nil
 	}
 
 	return nil
 }
 
-func (l *Level) removeMedkit(m *Medkit) {
-	l.medkitsToRemove = append(l.medkitsToRemove, m)
+func (l *Level) removePickup(p *Pickup) {
+	l.pickupsToRemove = append(l.pickupsToRemove, p)
+	l.items++
+}
+
+// splitScreen is true when more than one player plays on this machine.
+func (l *Level) splitScreen() bool {
+	return len(l.players) > 1 && l.game.client == nil
 }
 
+// render draws the level once for each local player, in viewports stacked from the top of the window.
 func (l *Level) render() {
+	if l.game.client != nil {
+		l.renderView(l.players[l.game.client.player])
+		return
+	}
+	if len(l.players) == 1 {
+		l.renderView(l.players[0])
+		return
+	}
+
+	height := int32(windowHeight / len(l.players))
+	for i, p := range l.players {
+		gl.Viewport(0, windowHeight-height*int32(i+1), windowWidth, height)
+		l.renderView(p)
+	}
+	gl.Viewport(0, 0, windowWidth, windowHeight)
+}
+
+// renderView draws the level as seen by the specified player.
+func (l *Level) renderView(p *Player) {
 	l.shader.bind()
+	l.shader.tint = p.tint()
 
-	l.shader.updateUniforms(l.transform.getProjectedTransformation(l.player.camera), l.material)
+	l.shader.updateUniforms(l.transform.getProjectedTransformation(p.camera), l.material)
 	l.mesh.draw()
 
 	for _, door := range l.doors {
-		door.render()
+		door.render(p.camera)
 	}
 
 	for _, monster := range l.monsters {
-		monster.render()
+		monster.render(p.camera)
 	}
 
-	for _, medkit := range l.medkits {
-		medkit.render()
+	for _, pickup := range l.pickups {
+		pickup.render(p.camera)
 	}
 
-	l.player.render()
+	p.render()
+	_hud.render(p)
 }
 
 func rectCollide(oldPos, newPos, size1, pos2, size2 Vector2f) (result Vector2f) {
@@ -256,8 +393,8 @@ func (l *Level) checkCollision(oldPos, newPos Vector3f, objectWidth, objectLengt
 		oldPos2 := Vector2f{oldPos.X, oldPos.Z}
 		newPos2 := Vector2f{newPos.X, newPos.Z}
 
-		for i := 0; i < l.level.width; i++ {
-			for j := 0; j < l.level.height; j++ {
+		for i := 0; i < l.level.Height; i++ {
+			for j := 0; j < l.level.Width; j++ {
 				if l.level.IsEmpty(i, j) {
 					collisionVector = collisionVector.mul(rectCollide(oldPos2, newPos2, objectSize, blockSize.mul(Vector2f{float32(i), float32(j)}), blockSize))
 				}
@@ -275,7 +412,9 @@ func (l *Level) checkCollision(oldPos, newPos Vector3f, objectWidth, objectLengt
 	return Vector3f{collisionVector.X, 0, collisionVector.Y}
 }
 
-func (l *Level) checkIntersections(lineStart, lineEnd Vector2f, hurtMonsters bool) *Vector2f {
+// checkIntersections returns the nearest intersection of the line with walls and doors;
+// when shooter is not nil the nearest monster before them is damaged.
+func (l *Level) checkIntersections(lineStart, lineEnd Vector2f, shooter *Player) *Vector2f {
 	var nearestIntersection *Vector2f
 
 	for i := 0; i < len(l.collisionPosStart); i++ {
@@ -292,14 +431,14 @@ func (l *Level) checkIntersections(lineStart, lineEnd Vector2f, hurtMonsters boo
 		nearestIntersection = findNearestVector2f(nearestIntersection, collisionVector, lineStart)
 	}
 
-	if hurtMonsters {
+	if shooter != nil {
 		var nearestMonsterIntersect *Vector2f
 		var nearestMonster *Monster
 
 		for _, monster := range l.monsters {
 			monsterPos3f := monster.transform.translation
 			monsterPos2f := Vector2f{monsterPos3f.X, monsterPos3f.Z}
-			collisionVector := lineIntersectRect(lineStart, lineEnd, monsterPos2f, defaultMonsterSize)
+			collisionVector := lineIntersectRect(lineStart, lineEnd, monsterPos2f, Vector2f{monster.def.Size, monster.def.Size})
 
 			nearestMonsterIntersect = findNearestVector2f(nearestMonsterIntersect, collisionVector, lineStart)
 
@@ -308,10 +447,33 @@ func (l *Level) checkIntersections(lineStart, lineEnd Vector2f, hurtMonsters boo
 			}
 		}
 
+		// in deathmatch other players can be shot too
+		var nearestPlayer *Player
+		if l.game.deathmatch {
+			for _, p := range l.players {
+				if p == shooter || p.dead() {
+					continue
+				}
+				collisionVector := lineIntersectRect(lineStart, lineEnd, Vector2f{p.camera.pos.X, p.camera.pos.Z}, Vector2f{defaultPlayer.size, defaultPlayer.size})
+				if collisionVector != nil && findNearestVector2f(nearestMonsterIntersect, collisionVector, lineStart) == collisionVector {
+					nearestMonsterIntersect, nearestMonster, nearestPlayer = collisionVector, nil, p
+				}
+			}
+		}
+
 		if nearestMonsterIntersect != nil && (nearestIntersection == nil ||
 			nearestMonsterIntersect.sub(lineStart).length() < nearestIntersection.sub(lineStart).length()) {
-			if nearestMonster != nil {
-				nearestMonster.damage(getPlayerDamage())
+			if nearestPlayer != nil {
+				nearestPlayer.damage(shooter.getDamage())
+				if nearestPlayer.dead() {
+					shooter.frags++
+				}
+			} else if nearestMonster != nil {
+				wasAlive := nearestMonster.alive()
+				nearestMonster.damage(shooter.getDamage())
+				if wasAlive && !nearestMonster.alive() {
+					shooter.score += killScore
+				}
 			}
 		}
 	}
@@ -348,7 +510,7 @@ func vector2fCross(a, b Vector2f) float32 {
 	return a.X*b.Y - a.Y*b.X
 }
 
-//http://stackoverflow.com/questions/563198/how-do-you-detect-where-two-line-segments-intersect
+// http://stackoverflow.com/questions/563198/how-do-you-detect-where-two-line-segments-intersect
 func lineIntersect(lineStart1, lineEnd1, lineStart2, lineEnd2 Vector2f) *Vector2f {
 	line1 := lineEnd1.sub(lineStart1)
 	line2 := lineEnd2.sub(lineStart2)
@@ -376,13 +538,13 @@ func (l *Level) generate() error {
 	var vertices []*Vertex
 	var indices []int32
 
-	for i := 0; i < l.level.width; i++ {
-		for j := 0; j < l.level.height; j++ {
+	for i := 0; i < l.level.Height; i++ {
+		for j := 0; j < l.level.Width; j++ {
 			if l.level.IsEmpty(i, j) {
 				continue
 			}
 
-			err := l.addSpecial(Special(l.level.specials[i][j]), i, j)
+			err := l.addSpecial(l.level.Special(i, j), i, j)
 			if err != nil {
 				return err
 			}
@@ -472,27 +634,46 @@ func calcTexCoords(value uint8) []float32 {
 	return result
 }
 
-func (l *Level) addSpecial(special Special, x, y int) error {
+func (l *Level) addSpecial(special wolfmap.Special, x, y int) error {
 	switch special {
-	case Empty:
+	case wolfmap.Empty:
 		return nil
-	case DoorSpecial:
+	case wolfmap.DoorSpecial:
 		err := l.addDoor(x, y)
 		if err != nil {
 			return err
 		}
-	case PlayerA:
-		l.player = l.game.NewPlayer(Vector3f{(float32(x) + 0.5) * spotWidth, 0.4375, (float32(y) + 0.5) * spotLength}, defaultPlayer.mesh, defaultGunMaterial)
-	case MonsterSpecial:
-		monsterTransform := l.game.NewTransform()
-		monsterTransform.translation = Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength}
-		l.monsters = append(l.monsters, l.game.NewMonster(monsterTransform, _defaultMonster.animations))
-	case SmallMedkit:
-		l.medkits = append(l.medkits, l.game.NewMedkit(Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength}))
-	case ExitSpecial:
+	case wolfmap.PlayerA:
+		l.players[0] = l.game.NewPlayer(Vector3f{(float32(x) + 0.5) * spotWidth, 0.4375, (float32(y) + 0.5) * spotLength}, defaultPlayer.mesh)
+	case wolfmap.PlayerB:
+		// ignored unless in co-op mode
+		if len(l.players) > 1 {
+			l.players[1] = l.game.NewPlayer(Vector3f{(float32(x) + 0.5) * spotWidth, 0.4375, (float32(y) + 0.5) * spotLength}, defaultPlayer.mesh)
+		}
+	case wolfmap.ExitSpecial:
 		l.exitPoints = append(l.exitPoints, &Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength})
+	case wolfmap.SecretSpecial:
+		l.secrets = append(l.secrets, &secret{pos: Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength}})
 	default:
-		panic(fmt.Sprintf("unrecognized blue value: %d", special))
+		if def, ok := monsterDefs[special]; ok {
+			if l.game.deathmatch {
+				break
+			}
+			monsterTransform := l.game.NewTransform()
+			monsterTransform.translation = Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength}
+			monster := l.game.NewMonster(monsterTransform, def)
+			monster.setActor(l.level.Actor(x, y))
+			l.monsters = append(l.monsters, monster)
+			break
+		}
+		if def, ok := pickupDefs[special]; ok {
+			pickup := l.game.NewPickup(Vector3f{(float32(x) + 0.5) * spotWidth, 0, (float32(y) + 0.5) * spotLength}, def)
+			pickup.id = len(l.pickups)
+			l.pickups = append(l.pickups, pickup)
+			break
+		}
+		// maps are not fully validated when loaded, and monster types come from an editable file
+		return fmt.Errorf("unrecognized special '%c' at %d,%d", special, x, y)
 	}
 
 	return nil
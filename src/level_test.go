@@ -0,0 +1,356 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+var (
+	towardsPlusX  = Vector3f{1, 0, 0}
+	towardsPlusZ  = Vector3f{0, 0, 1}
+	towardsMinusZ = Vector3f{0, 0, -1}
+)
+
+func TestLevelsLoad(t *testing.T) {
+	for _, tc := range []struct {
+		levelNum                 uint
+		doors, monsters, pickups int
+	}{
+		{1, 4, 6, 4},
+		{2, 15, 12, 6},
+		{3, 26, 53, 26},
+	} {
+		h := newHarness(t, tc.levelNum)
+		l := h.level()
+		if len(l.doors) != tc.doors || len(l.monsters) != tc.monsters || len(l.pickups) != tc.pickups {
+			t.Errorf("level %d: expected %d doors, %d monsters, %d pickups but got %d, %d, %d", tc.levelNum,
+				tc.doors, tc.monsters, tc.pickups, len(l.doors), len(l.monsters), len(l.pickups))
+		}
+		if h.player().health != defaultPlayer.maxHealth {
+			t.Errorf("level %d: player starts with %d health", tc.levelNum, h.player().health)
+		}
+		for _, m := range l.monsters {
+			if m.state != stateIdle {
+				t.Errorf("level %d: monster starts in state %d", tc.levelNum, m.state)
+			}
+		}
+	}
+}
+
+func TestUnknownSpecial(t *testing.T) {
+	h := newHarness(t, 1)
+	l := h.level()
+
+	// a monster type missing from the monster definitions is reported like any unknown special
+	dog := monsterDefs[wolfmap.DogSpecial]
+	delete(monsterDefs, wolfmap.DogSpecial)
+	defer func() {
+		monsterDefs[wolfmap.DogSpecial] = dog
+	}()
+	for _, special := range []wolfmap.Special{wolfmap.DogSpecial, '?'} {
+		err := l.addSpecial(special, 13, 20)
+		if err == nil || !strings.Contains(err.Error(), "13,20") {
+			t.Errorf("'%c': expected an error but got %v", special, err)
+		}
+	}
+}
+
+func TestWallCollision(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+
+	// there is a wall right after the start position
+	h.placePlayer(9, 24, towardsPlusZ)
+	h.script.hold(inputForward, 500)
+	h.runScript()
+
+	pos := h.player().camera.pos
+	if pos.Z > 25-defaultPlayer.size || pos.X != 9.5 {
+		t.Errorf("player walked through the wall: %s", pos.String())
+	}
+}
+
+func TestWalkForward(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+
+	h.placePlayer(13, 12, towardsPlusZ)
+	h.script.hold(inputForward, 250)
+	h.runScript()
+
+	// one second of movement in an open corridor
+	pos := h.player().camera.pos
+	expected := float32(12.5) + defaultPlayer.moveSpeed
+	if d := pos.Z - expected; d > 0.01 || d < -0.01 {
+		t.Errorf("expected player at Z=%.3f but is at %s", expected, pos.String())
+	}
+}
+
+func TestDoorOpensAndCloses(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+
+	door := h.doorAt(12, 23)
+	h.placePlayerAt(11.8, 23.5, towardsPlusX)
+	h.script.hold(inputUse, 1)
+	h.runScript()
+
+	if !door.isOpening {
+		t.Fatal("door did not open")
+	}
+
+	h.runFor(timeToOpen)
+	if door.transform.translation != door.openPosition {
+		t.Errorf("door is not open: %s", door.transform.translation.String())
+	}
+
+	// door can be walked through while open
+	h.script.hold(inputForward, 250)
+	h.runScript()
+	if h.player().camera.pos.X < 13 {
+		t.Errorf("player did not walk through open door: %s", h.player().camera.pos.String())
+	}
+
+	h.runFor(closeDelay + timeToOpen)
+	if door.isOpening || door.transform.translation != door.closePosition {
+		t.Errorf("door did not close: %s", door.transform.translation.String())
+	}
+}
+
+func TestClosedDoorBlocksPlayer(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+
+	h.placePlayer(11, 23, towardsPlusX)
+	h.script.hold(inputForward, 250)
+	h.runScript()
+
+	if h.player().camera.pos.X > 12.5 {
+		t.Errorf("player walked through closed door: %s", h.player().camera.pos.String())
+	}
+}
+
+func TestShootMonster(t *testing.T) {
+	h := newHarness(t, 1)
+
+	monster := h.monsterAt(13, 28)
+	h.placePlayerAt(13.6, 24.5, towardsPlusZ)
+	// the pistol fires 4 times per second and up to 5 shots are needed
+	for i := 0; i < 375 && monster.state != stateDying; i++ {
+		h.script.hold(inputFire, 1).idle(1)
+		h.runScript()
+	}
+
+	if monster.state != stateDying {
+		t.Fatalf("monster should be dying but is in state %d (health %d)", monster.state, monster.health)
+	}
+
+	// the dying animation ends at the time of its last frame
+	h.runFor(monster.def.dyingEnd[len(monster.def.dyingEnd)-1])
+	if monster.state != stateDead {
+		t.Errorf("monster should be dead but is in state %d", monster.state)
+	}
+}
+
+func TestMonsterSpotsPlayer(t *testing.T) {
+	h := newHarness(t, 1)
+
+	monster := h.monsterAt(13, 28)
+	h.placePlayerAt(13.6, 24.5, towardsPlusZ)
+	h.runFor(time.Second)
+
+	if monster.state == stateIdle {
+		t.Fatal("monster did not spot the player")
+	}
+
+	h.runFor(20 * time.Second)
+	if h.player().health == defaultPlayer.maxHealth {
+		t.Error("monster did not hurt the player")
+	}
+}
+
+func TestMonsterDoesNotSeeThroughWalls(t *testing.T) {
+	h := newHarness(t, 1)
+
+	// both monsters are in a room behind a closed door
+	h.placePlayer(19, 12, towardsPlusZ)
+	h.runFor(5 * time.Second)
+
+	for _, m := range []*Monster{h.monsterAt(17, 18), h.monsterAt(22, 18)} {
+		if m.state != stateIdle {
+			t.Errorf("monster at %s is in state %d", m.transform.translation.String(), m.state)
+		}
+	}
+}
+
+func TestMedkitPickup(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+
+	medkits := len(h.level().pickups)
+	heal := pickupDefs[wolfmap.SmallMedkit].heal
+	h.player().health = 50
+	h.placePlayer(5, 22, towardsMinusZ)
+	h.run(1)
+
+	if h.player().health != 50+heal {
+		t.Errorf("expected health %d but got %d", 50+heal, h.player().health)
+	}
+	if len(h.level().pickups) != medkits-1 {
+		t.Errorf("medkit was not removed")
+	}
+
+	// medkits are not picked up at full health
+	h.player().health = defaultPlayer.maxHealth
+	h.placePlayer(8, 5, towardsMinusZ)
+	h.run(1)
+	if len(h.level().pickups) != medkits-1 {
+		t.Errorf("medkit was picked up at full health")
+	}
+}
+
+func TestLevelExit(t *testing.T) {
+	for _, tc := range []struct {
+		levelNum uint
+		x, y     int
+	}{
+		{1, 5, 26},
+		{2, 27, 50},
+	} {
+		h := newHarness(t, tc.levelNum)
+		h.removeMonsters()
+
+		h.placePlayer(tc.x, tc.y, towardsPlusZ)
+		h.script.hold(inputUse, 10)
+		h.runScript()
+
+		if h.g.state != gameLevelComplete || h.g.levelNum != tc.levelNum {
+			t.Errorf("level %d: exit did not complete the level", tc.levelNum)
+		}
+
+		// holding use does not skip to the next level
+		h.script.idle(1).hold(inputUse, 1)
+		h.runScript()
+
+		if h.g.state != gamePlaying || h.g.levelNum != tc.levelNum+1 {
+			t.Errorf("level %d: exit did not load next level", tc.levelNum)
+		}
+	}
+}
+
+func TestLastLevelExit(t *testing.T) {
+	h := newHarness(t, 3)
+	h.removeMonsters()
+	start := h.player().camera.pos
+
+	h.placePlayer(46, 37, towardsPlusZ)
+	h.script.hold(inputUse, 1).idle(1).hold(inputUse, 1)
+	h.runScript()
+
+	if h.g.state != gameVictory {
+		t.Fatalf("expected victory after the last level but state is %d", h.g.state)
+	}
+	if h.g.levelNum != 3 {
+		t.Errorf("no level should be loaded after the last one, but level is %d", h.g.levelNum)
+	}
+
+	h.script.hold(inputRestartGame, 1)
+	h.runScript()
+	if h.g.state != gamePlaying || h.g.levelNum != 3 || h.player().camera.pos != start {
+		t.Error("game did not restart from the level it started from")
+	}
+}
+
+func TestGameOverRestartLevel(t *testing.T) {
+	h := newHarness(t, 1)
+	start := h.player().camera.pos
+
+	h.placePlayer(13, 24, towardsPlusZ)
+	h.player().damage(defaultPlayer.maxHealth)
+	if h.g.state != gameOver {
+		t.Fatalf("expected game over but state is %d", h.g.state)
+	}
+
+	// the game stays still until the player restarts the level
+	monster := h.monsterAt(13, 28)
+	position := monster.transform.translation
+	h.runFor(time.Second)
+	if h.g.state != gameOver || monster.transform.translation != position {
+		t.Fatal("game should stay still while over")
+	}
+
+	h.script.hold(inputUse, 1)
+	h.runScript()
+	if h.g.state != gamePlaying || h.player().dead() || h.player().camera.pos != start || h.g.levelNum != 1 {
+		t.Errorf("level did not restart: state %d, health %d, position %s", h.g.state, h.player().health, h.player().camera.pos.String())
+	}
+}
+
+func TestRestartLevelWhilePlaying(t *testing.T) {
+	h := newHarness(t, 2)
+	start := h.player().camera.pos
+
+	h.script.hold(inputForward, 100).hold(inputRestartLevel, 1)
+	h.runScript()
+
+	if h.player().camera.pos != start || h.g.levelNum != 2 || h.g.levelLoads != 2 {
+		t.Errorf("level did not restart: level %d, position %s", h.g.levelNum, h.player().camera.pos.String())
+	}
+}
+
+func TestFailedRestartKeepsLevel(t *testing.T) {
+	h := newHarness(t, 2)
+	level, loads := h.level(), h.g.levelLoads
+
+	h.g.mapName, h.g.startMap = "missing.map", "missing.map"
+	if h.g.restartLevel() == nil || h.g.restartGame() == nil {
+		t.Fatal("expected an error restarting from a missing map")
+	}
+	if h.level() != level || h.g.levelNum != 2 || h.g.levelLoads != loads {
+		t.Errorf("failed restart changed the game: level %d, loads %d", h.g.levelNum, h.g.levelLoads)
+	}
+}
+
+func TestDeterministicReplay(t *testing.T) {
+	play := func() *harness {
+		h := newHarness(t, 1)
+		h.placePlayerAt(13.6, 20.5, towardsPlusZ)
+		h.script.hold(inputForward, 100).hold(inputFire, 50).look(Vector2f{15, 0}).hold(inputFire|inputLeft, 200).idle(1000)
+		h.runScript()
+		return h
+	}
+
+	a, b := play(), play()
+
+	if a.player().health != b.player().health || a.player().camera.pos != b.player().camera.pos {
+		t.Errorf("player state differs: %d %s vs %d %s", a.player().health, a.player().camera.pos.String(),
+			b.player().health, b.player().camera.pos.String())
+	}
+	for i := range a.level().monsters {
+		ma, mb := a.level().monsters[i], b.level().monsters[i]
+		if ma.state != mb.state || ma.health != mb.health || ma.transform.translation != mb.transform.translation {
+			t.Errorf("monster %d differs", i)
+		}
+	}
+}
@@ -19,8 +19,10 @@ with this program; if not, write to the Free Software Foundation, Inc.,
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"runtime"
 	"time"
@@ -36,23 +38,46 @@ const (
 	printFPS       = true         // print FPS count every second
 	debugLevelTest = false        // will load 'levelTest.map'
 	frameCap       = float64(250) // cap max framerate to this number of FPS
+	windowWidth    = 800
+	windowHeight   = 600
+	maxPlayers     = 2
 )
 
+// fixed simulation step; all game updates advance by this amount
+const frameTime = time.Duration(1000/frameCap) * time.Millisecond
+
 var (
 	Window *glfw.Window
 	G      *Game
-	random *rand.Rand
+
+	// when headless no window nor GL context is created: textures, meshes and shaders
+	// are null handles and only the simulation (map, collisions, doors, monsters and pickups) runs
+	headless      bool
+	headlessTicks uint
+	seed          int64
+	startLevel    uint
+	startMap      string
+	recordDemo    string
+	playDemo      string
+	coop          bool
+	serverAddr    string
+	serverPlayers uint
+	deathmatch    bool
+	connectAddr   string
+	statsFile     string
 )
 
 func init() {
 	// This is needed to arrange that main() runs on main thread.
 	runtime.LockOSThread()
-
-	random = rand.New(rand.NewSource(time.Now().Unix()))
 }
 
 func fatalError(err error) {
 	fmt.Fprintf(os.Stderr, "FATAL: %v\n", err)
+	if G != nil {
+		// keep what was recorded so far
+		G.shutdown()
+	}
 	os.Exit(1)
 }
 
@@ -83,6 +108,50 @@ WolfenGo comes with ABSOLUTELY NO WARRANTY.
 This is free software, and you are welcome to redistribute it
 under GNU/GPLv2 license.`+"\n", version)
 
+	flag.BoolVar(&headless, "headless", false, "run the simulation without a window or GL context")
+	flag.UintVar(&headlessTicks, "ticks", 0, "number of simulation ticks to run in headless mode (0 = until the game stops)")
+	flag.Int64Var(&seed, "seed", 0, "seed for the game random number generator (0 = pick one from current time)")
+	flag.UintVar(&startLevel, "level", 1, "level to start from")
+	flag.StringVar(&startMap, "map", "", "map file (in the maps directory) to start from, instead of the default one for the level")
+	flag.StringVar(&recordDemo, "record", "", "record input to the specified demo file (.wdemo)")
+	flag.StringVar(&playDemo, "playdemo", "", "play back input from the specified demo file (.wdemo)")
+	flag.BoolVar(&coop, "coop", false, "two players co-op mode, with split-screen")
+	flag.StringVar(&serverAddr, "server", "", "run a dedicated server listening on the specified UDP address (e.g. :7000)")
+	flag.UintVar(&serverPlayers, "players", 2, "number of players the dedicated server waits for")
+	flag.BoolVar(&deathmatch, "deathmatch", false, "dedicated server runs a deathmatch instead of a co-op game")
+	flag.StringVar(&connectAddr, "connect", "", "join the game of the server at the specified address (host:port)")
+	flag.StringVar(&statsFile, "stats", "", "append the statistics of each completed level as JSON lines to the specified file")
+	flag.Parse()
+
+	if serverAddr != "" {
+		err := runServer()
+		if err != nil {
+			fatalError(err)
+		}
+		return
+	}
+
+	if headless {
+		err := loadAssets()
+		if err != nil {
+			fatalError(err)
+		}
+		G, err = setupGame()
+		if err != nil {
+			fatalError(err)
+		}
+
+		err = runHeadless(headlessTicks)
+		if err != nil {
+			fatalError(err)
+		}
+		err = G.shutdown()
+		if err != nil {
+			fatalError(err)
+		}
+		return
+	}
+
 	if err := glfw.Init(); err != nil {
 		fatalError(err)
 	}
@@ -102,7 +171,7 @@ under GNU/GPLv2 license.`+"\n", version)
 	glfw.WindowHint(glfw.Resizable, glfw.False)
 	glfw.WindowHint(glfw.DoubleBuffer, glfw.True)
 	var err error
-	Window, err = glfw.CreateWindow(800, 600, "WolfenGo", nil, nil)
+	Window, err = glfw.CreateWindow(windowWidth, windowHeight, "WolfenGo", nil, nil)
 	if err != nil {
 		fatalError(err)
 	}
@@ -137,35 +206,24 @@ under GNU/GPLv2 license.`+"\n", version)
 	gl.Enable(gl.DEPTH_CLAMP)
 	gl.Enable(gl.TEXTURE_2D)
 
-	// load all assets
-	_, err = getBasicShader()
-	if err != nil {
-		fatalError(err)
-	}
-	err = _defaultMedkit.initMedkit()
-	if err != nil {
-		fatalError(err)
-	}
-	err = _defaultMonster.initMonster()
-	if err != nil {
-		fatalError(err)
-	}
-	getDoorMesh()
-	initPlayer()
-	err = initGun()
+	err = loadAssets()
 	if err != nil {
 		fatalError(err)
 	}
 
-	G, err = NewGame()
+	G, err = setupGame()
 	if err != nil {
 		fatalError(err)
 	}
 
 	var frames uint64
 	var frameCounter time.Duration
+	var quick quickKeys
+	menu := newMenus(G)
+	if G.client == nil && playDemo == "" && recordDemo == "" {
+		menu.open(menuMain)
+	}
 
-	frameTime := time.Duration(1000/frameCap) * time.Millisecond
 	lastTime := time.Now()
 	var unprocessedTime time.Duration
 
@@ -184,16 +242,30 @@ under GNU/GPLv2 license.`+"\n", version)
 			render = true
 
 			unprocessedTime -= frameTime
-			if Window.ShouldClose() {
-				goto Exit
-			}
-			G.timeDelta = frameTime.Seconds()
 
 			glfw.PollEvents()
-			err := G.input()
+			err := menu.poll()
 			if err != nil {
 				fatalError(err)
 			}
+			if menu.quit {
+				goto Exit
+			}
+			// does not advance while paused
+			G.tick()
+
+			// players input is ignored while a menu is shown
+			if !menu.active() {
+				err = G.input()
+				if err == errDemoFinished {
+					fmt.Println(err)
+					goto Exit
+				}
+				if err != nil {
+					fatalError(err)
+				}
+				quickSaveLoad(quick.poll())
+			}
 			err = G.update()
 			if err != nil {
 				fatalError(err)
@@ -212,6 +284,7 @@ under GNU/GPLv2 license.`+"\n", version)
 			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
 			G.render()
+			menu.render()
 			frames++
 			Window.SwapBuffers()
 		} else {
@@ -221,4 +294,173 @@ under GNU/GPLv2 license.`+"\n", version)
 
 Exit:
 	Window.Destroy()
+	err = G.shutdown()
+	if err != nil {
+		fatalError(err)
+	}
+}
+
+// runServer runs a dedicated server until the game stops running.
+func runServer() error {
+	headless = true
+	err := loadAssets()
+	if err != nil {
+		return err
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s, err := listenServer(serverAddr, int(serverPlayers), deathmatch, seed, startLevel, startMap)
+	if err != nil {
+		return err
+	}
+	G = s.game
+
+	err = s.run()
+	if err != nil {
+		s.stop()
+		return err
+	}
+	return s.stop()
+}
+
+// quickSaveLoad saves or loads the game as requested by the player; failures are not fatal.
+func quickSaveLoad(save, load bool) {
+	if !save && !load {
+		return
+	}
+	if playDemo != "" || recordDemo != "" {
+		fmt.Println("quicksave is not available with demos")
+		return
+	}
+
+	var err error
+	if save {
+		err = G.save(quickSaveFile)
+	} else {
+		err = G.load(quickSaveFile)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// setupGame creates a new game with the input sources and random seed selected via command-line flags.
+func setupGame() (*Game, error) {
+	if coop && (playDemo != "" || recordDemo != "") {
+		return nil, errors.New("demos are not supported in co-op mode")
+	}
+	if connectAddr != "" {
+		if headless || coop || playDemo != "" || recordDemo != "" {
+			return nil, errors.New("network clients support neither headless, co-op nor demos")
+		}
+		return connect(connectAddr, &windowInput{bindings: &firstPlayerBindings})
+	}
+
+	var source inputSource
+	if playDemo != "" {
+		demo, err := openDemo(playDemo)
+		if err != nil {
+			return nil, err
+		}
+		seed, startLevel, startMap = demo.seed, demo.level, demo.mapName
+		source = demo
+	} else if !headless {
+		source = &windowInput{bindings: &firstPlayerBindings}
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Println("random seed:", seed)
+
+	if startMap == "" {
+		startMap = levelFileName(startLevel)
+	}
+
+	if recordDemo != "" {
+		if source == nil {
+			return nil, fmt.Errorf("no input to record")
+		}
+		recorder, err := createDemo(recordDemo, demoHeader{startLevel, startMap, seed}, source)
+		if err != nil {
+			return nil, err
+		}
+		source = recorder
+	}
+
+	sources := []inputSource{source}
+	if coop {
+		var second inputSource
+		if !headless {
+			second = &windowInput{bindings: &secondPlayerBindings}
+		}
+		sources = append(sources, second)
+	}
+
+	g, err := NewGame(seed, startLevel, startMap, false, sources...)
+	if err != nil {
+		if c, ok := source.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+
+	if statsFile != "" {
+		g.statsLog, err = os.OpenFile(statsFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			g.shutdown()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// loadAssets loads all textures, meshes and shaders; in headless mode these are null handles.
+func loadAssets() error {
+	_, err := getBasicShader()
+	if err != nil {
+		return err
+	}
+	err = _defaultPickup.initPickups()
+	if err != nil {
+		return err
+	}
+	err = loadMonsterDefs(monsterDefsFile)
+	if err != nil {
+		return err
+	}
+	getDoorMesh()
+	initPlayer()
+	_hud.initHUD()
+	return initWeapons()
+}
+
+// runHeadless advances the simulation by fixed steps as fast as possible, without rendering.
+// It stops after the specified number of ticks (if non-zero), when the game is won or
+// when it is not being played and there is no input to restart or continue it.
+func runHeadless(ticks uint) error {
+	var tick uint
+	for ; ticks == 0 || tick < ticks; tick++ {
+		if G.state == gameVictory || (G.state != gamePlaying && G.inputSources[0] == nil) {
+			break
+		}
+		G.tick()
+
+		err := G.input()
+		if err == errDemoFinished {
+			fmt.Println(err)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		err = G.update()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("headless simulation ran for %d ticks (%v of game time)\n", tick, time.Duration(tick)*frameTime)
+	return nil
 }
@@ -0,0 +1,69 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+// Command wolfengo-mapcheck validates WolfenGo .map files, reporting all problems found.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s file.map [file.map...]\n", filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+
+	var failed bool
+	for _, fileName := range os.Args[1:] {
+		if !checkFile(fileName) {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkFile prints all problems found in the map file and returns true if there are none.
+func checkFile(fileName string) bool {
+	m, err := wolfmap.ReadFile(fileName)
+	if err != nil {
+		if me, ok := err.(wolfmap.Error); ok {
+			if se, ok := me.Err.(*wolfmap.SyntaxError); ok {
+				fmt.Printf("%s:%d: %s\n", fileName, se.Line, se.Msg)
+				return false
+			}
+		}
+		fmt.Println(err)
+		return false
+	}
+
+	problems := wolfmap.Check(m)
+	for _, p := range problems {
+		fmt.Printf("%s:%s\n", fileName, p.String())
+	}
+
+	return len(problems) == 0
+}
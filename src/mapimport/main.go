@@ -0,0 +1,93 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+// Command wolfengo-mapimport converts the levels of original Wolfenstein 3D
+// MAPHEAD and GAMEMAPS files to WolfenGo .map files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+func main() {
+	var prefix string
+	flag.StringVar(&prefix, "prefix", "wolf", "prefix of the converted map file names")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-prefix wolf] MAPHEAD.WL1 GAMEMAPS.WL1 outdir\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	gm, err := wolfmap.OpenGameMaps(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	outDir := flag.Arg(2)
+	mapFileName := func(n int) string {
+		return fmt.Sprintf("%s%02d.map", prefix, n+1)
+	}
+
+	for n := 0; n < gm.NumLevels(); n++ {
+		m, err := gm.Level(n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if n+1 < gm.NumLevels() {
+			m.Next = mapFileName(n + 1)
+		}
+
+		fileName := filepath.Join(outDir, mapFileName(n))
+		err = writeMap(fileName, m)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", fileName, m.Title)
+
+		// imported levels are not necessarily playable, e.g. exits may be reachable only via secret passages
+		for _, p := range wolfmap.Check(m) {
+			fmt.Printf("%s:%s\n", fileName, p.String())
+		}
+	}
+}
+
+func writeMap(fileName string, m *wolfmap.Map) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	err = m.Write(f)
+	if err != nil {
+		f.Close()
+		return wolfmap.Error{FileName: fileName, Err: err}
+	}
+	return f.Close()
+}
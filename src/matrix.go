@@ -185,6 +185,29 @@ func (m *Matrix4f) initProjection(fov, width, height, zNear, zFar float32) {
 	m[3][3] = 0
 }
 
+// initOrthographic sets a parallel projection of the specified box to the clip space,
+// as used by 2D overlays.
+func (m *Matrix4f) initOrthographic(left, right, bottom, top, zNear, zFar float32) {
+	width, height, depth := right-left, top-bottom, zFar-zNear
+
+	m[0][0] = 2 / width
+	m[0][1] = 0
+	m[0][2] = 0
+	m[0][3] = -(right + left) / width
+	m[1][0] = 0
+	m[1][1] = 2 / height
+	m[1][2] = 0
+	m[1][3] = -(top + bottom) / height
+	m[2][0] = 0
+	m[2][1] = 0
+	m[2][2] = -2 / depth
+	m[2][3] = -(zFar + zNear) / depth
+	m[3][0] = 0
+	m[3][1] = 0
+	m[3][2] = 0
+	m[3][3] = 1
+}
+
 func (m *Matrix4f) initCamera(forward, up Vector3f) {
 	f, r := forward.normalised(), up.normalised()
 	r = r.cross(f)
@@ -0,0 +1,407 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gdm85/wolfengo/src/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+type menuScreen int
+
+const (
+	menuMain menuScreen = iota
+	menuPause
+	menuOptions
+	// confirmation before quitting
+	menuQuit
+)
+
+const (
+	// menu layout, in window pixels from the bottom
+	menuTitleY     = windowHeight * 3 / 4
+	menuTitleScale = 5
+	menuItemsY     = menuTitleY - 60
+	menuItemHeight = 36
+	menuItemScale  = 3
+	// extra space around the items that reacts to the mouse
+	menuItemMargin = 8
+
+	minMouseSpeed, maxMouseSpeed = 1, 10
+	defaultMouseSpeed            = 5
+)
+
+var (
+	menuDisabledColor = Vector3f{0.4, 0.4, 0.4}
+	// alpha of the shade drawn over the level
+	menuShadeAlpha = uint8(160)
+)
+
+// options can be changed from the menu; they are applied when reading the window input,
+// thus demos and network games replay what the player did with the options of the time.
+var options = struct {
+	mouseSpeed  int
+	invertMouse bool
+}{mouseSpeed: defaultMouseSpeed}
+
+type menuItem struct {
+	label    string
+	disabled bool
+	action   func() error
+	// changes the value of options, nil for the other items
+	change func(delta int)
+}
+
+// menus is the menu overlay: the game is paused while a menu is shown, with the exception of network games.
+// Menus are stacked, going back returns to the menu that opened the current one.
+type menus struct {
+	game     *Game
+	stack    []menuScreen
+	items    []menuItem
+	selected int
+	// shown below the items, e.g. when loading the game fails
+	message string
+	// set when the player confirms to quit
+	quit bool
+
+	// keys and mouse button held in the previous poll, as actions are triggered when pressed
+	held       map[glfw.Key]bool
+	mouseHeld  bool
+	mouseItem  int
+	lastCursor Vector2f
+}
+
+// menuKeys are the keys read by the menus.
+var menuKeys = []glfw.Key{
+	glfw.KeyUp, glfw.KeyDown, glfw.KeyLeft, glfw.KeyRight,
+	glfw.KeyEnter, glfw.KeyKPEnter, glfw.KeySpace, glfw.KeyEscape, glfw.KeyQ,
+}
+
+func newMenus(g *Game) *menus {
+	return &menus{game: g, held: make(map[glfw.Key]bool), mouseItem: -1}
+}
+
+func (m *menus) active() bool {
+	return len(m.stack) > 0
+}
+
+func (m *menus) screen() menuScreen {
+	return m.stack[len(m.stack)-1]
+}
+
+// open shows the specified menu on top of the current one, pausing the game.
+func (m *menus) open(screen menuScreen) {
+	if !m.active() {
+		m.game.setPaused(true)
+		if Window != nil {
+			Window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+		}
+	}
+	m.stack = append(m.stack, screen)
+	m.message = ""
+	m.refresh()
+	m.selected = 0
+	m.move(0)
+}
+
+// back returns to the previous menu; the main menu shown when the game starts cannot be left this way.
+func (m *menus) back() {
+	if len(m.stack) == 1 && m.screen() == menuMain {
+		return
+	}
+	m.stack = m.stack[:len(m.stack)-1]
+	if !m.active() {
+		m.close()
+		return
+	}
+	m.message = ""
+	m.refresh()
+	m.selected = 0
+	m.move(0)
+}
+
+// close hides all menus and resumes the game.
+func (m *menus) close() {
+	m.stack = nil
+	m.items = nil
+	m.game.setPaused(false)
+}
+
+// refresh builds the items of the current menu.
+func (m *menus) refresh() {
+	g := m.game
+	// these would not be replayed by demos, nor can be done by network clients
+	canRestart := g.client == nil && playDemo == "" && recordDemo == ""
+	canSave := canRestart && !g.deathmatch
+
+	switch m.screen() {
+	case menuMain:
+		m.items = []menuItem{
+			{label: "new game", disabled: !canRestart, action: m.newGame},
+			{label: "load game", disabled: !canSave, action: m.loadGame},
+			{label: "options", action: func() error { m.open(menuOptions); return nil }},
+			{label: "quit", action: func() error { m.open(menuQuit); return nil }},
+		}
+	case menuPause:
+		m.items = []menuItem{
+			{label: "resume", action: func() error { m.close(); return nil }},
+			{label: "save game", disabled: !canSave, action: m.saveGame},
+			{label: "load game", disabled: !canSave, action: m.loadGame},
+			{label: "options", action: func() error { m.open(menuOptions); return nil }},
+			{label: "main menu", disabled: !canRestart, action: func() error { m.open(menuMain); return nil }},
+			{label: "quit", action: func() error { m.open(menuQuit); return nil }},
+		}
+	case menuOptions:
+		m.items = []menuItem{
+			{label: fmt.Sprintf("mouse speed: %d", options.mouseSpeed), change: changeMouseSpeed},
+			{label: "invert mouse: " + onOff(options.invertMouse), change: func(int) { options.invertMouse = !options.invertMouse }},
+			{label: "back", action: func() error { m.back(); return nil }},
+		}
+	case menuQuit:
+		m.items = []menuItem{
+			{label: "no", action: func() error { m.back(); return nil }},
+			{label: "yes", action: func() error { m.quit = true; return nil }},
+		}
+	}
+}
+
+func (m *menus) title() string {
+	switch m.screen() {
+	case menuMain:
+		return "wolfengo"
+	case menuPause:
+		return "paused"
+	case menuOptions:
+		return "options"
+	}
+	return "quit the game?"
+}
+
+func changeMouseSpeed(delta int) {
+	options.mouseSpeed += delta
+	if options.mouseSpeed > maxMouseSpeed {
+		options.mouseSpeed = minMouseSpeed
+	} else if options.mouseSpeed < minMouseSpeed {
+		options.mouseSpeed = maxMouseSpeed
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func (m *menus) newGame() error {
+	err := m.game.restartGame()
+	if err != nil {
+		return err
+	}
+	m.close()
+	return nil
+}
+
+func (m *menus) saveGame() error {
+	err := m.game.save(quickSaveFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		m.message = "cannot save the game"
+		return nil
+	}
+	m.close()
+	return nil
+}
+
+func (m *menus) loadGame() error {
+	err := m.game.load(quickSaveFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		m.message = "cannot load the game"
+		return nil
+	}
+	m.close()
+	return nil
+}
+
+// move selects the next enabled item in the specified direction, wrapping around;
+// with a zero delta the current item is kept if enabled.
+func (m *menus) move(delta int) {
+	step := delta
+	if step == 0 {
+		step = 1
+	}
+	i := m.selected + delta
+	for range m.items {
+		i = (i + len(m.items)) % len(m.items)
+		if !m.items[i].disabled {
+			m.selected = i
+			return
+		}
+		i += step
+	}
+}
+
+// activate triggers the selected item.
+func (m *menus) activate() error {
+	item := &m.items[m.selected]
+	if item.disabled {
+		return nil
+	}
+	if item.change != nil {
+		m.change(1)
+		return nil
+	}
+	return item.action()
+}
+
+// change alters the option of the selected item, if any.
+func (m *menus) change(delta int) {
+	if item := &m.items[m.selected]; item.change != nil {
+		item.change(delta)
+		m.refresh()
+	}
+}
+
+// itemY returns the bottom of the specified item.
+func itemY(i int) float32 {
+	return float32(menuItemsY - i*menuItemHeight)
+}
+
+// itemAt returns the item at the specified window coordinates (origin at the bottom left), or -1.
+func (m *menus) itemAt(x, y float32) int {
+	for i, item := range m.items {
+		halfWidth := textWidth(item.label, menuItemScale)/2 + menuItemMargin
+		bottom := itemY(i) - menuItemMargin
+		top := itemY(i) + glyphHeight*menuItemScale + menuItemMargin
+		if x >= windowWidth/2-halfWidth && x <= windowWidth/2+halfWidth && y >= bottom && y <= top {
+			return i
+		}
+	}
+	return -1
+}
+
+// pressed returns true when the key has been pressed since the previous poll.
+func (m *menus) pressed(key glfw.Key) bool {
+	return Window.GetKey(key) == glfw.Press && !m.held[key]
+}
+
+// poll reads keyboard and mouse: ESC opens the pause menu and Q (or closing the window) asks to quit,
+// arrows, enter and mouse navigate the menus.
+func (m *menus) poll() error {
+	defer func() {
+		for _, key := range menuKeys {
+			m.held[key] = Window.GetKey(key) == glfw.Press
+		}
+	}()
+
+	if Window.ShouldClose() {
+		Window.SetShouldClose(false)
+		if !m.active() || m.screen() != menuQuit {
+			m.open(menuQuit)
+		}
+		return nil
+	}
+
+	if !m.active() {
+		switch {
+		case m.pressed(glfw.KeyEscape):
+			m.open(menuPause)
+		case m.pressed(glfw.KeyQ):
+			m.open(menuQuit)
+		}
+		return nil
+	}
+
+	switch {
+	case m.pressed(glfw.KeyUp):
+		m.move(-1)
+	case m.pressed(glfw.KeyDown):
+		m.move(1)
+	case m.pressed(glfw.KeyLeft):
+		m.change(-1)
+	case m.pressed(glfw.KeyRight):
+		m.change(1)
+	case m.pressed(glfw.KeyEnter), m.pressed(glfw.KeyKPEnter), m.pressed(glfw.KeySpace):
+		return m.activate()
+	case m.pressed(glfw.KeyEscape):
+		m.back()
+	case m.pressed(glfw.KeyQ) && m.screen() != menuQuit:
+		m.open(menuQuit)
+	}
+	if !m.active() {
+		return nil
+	}
+
+	// window coordinates have the origin at the top left
+	x, y := Window.GetCursorPos()
+	cursor := Vector2f{float32(x), float32(y)}
+	i := m.itemAt(cursor.X, windowHeight-cursor.Y)
+	if cursor != m.lastCursor && i >= 0 && !m.items[i].disabled {
+		m.selected = i
+	}
+	m.lastCursor = cursor
+
+	// items are triggered when the button is released over the item it was pressed on
+	mouseDown := Window.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+	defer func() { m.mouseHeld = mouseDown }()
+	if mouseDown && !m.mouseHeld {
+		m.mouseItem = i
+	} else if !mouseDown && m.mouseHeld && i >= 0 && i == m.mouseItem && !m.items[i].disabled {
+		m.selected = i
+		return m.activate()
+	}
+	return nil
+}
+
+// render draws the current menu over the whole window.
+func (m *menus) render() {
+	if !m.active() {
+		return
+	}
+	h := &_hud
+	s := m.game.level.shader
+	s.bind()
+	s.tint = Vector3f{1, 1, 1}
+	screen := &Camera{width: windowWidth, height: windowHeight}
+
+	gl.Disable(gl.DEPTH_TEST)
+	defer gl.Enable(gl.DEPTH_TEST)
+
+	h.drawQuad(s, screen, 0, 0, windowWidth, windowHeight, h.shade)
+	h.drawCentered(s, screen, windowWidth/2, menuTitleY, menuTitleScale, m.title(), hudTitleColor)
+	for i, item := range m.items {
+		color := hudValueColor
+		label := item.label
+		switch {
+		case item.disabled:
+			color = menuDisabledColor
+		case i == m.selected:
+			color = hudTitleColor
+			label = "> " + label + " <"
+		}
+		h.drawCentered(s, screen, windowWidth/2, itemY(i), menuItemScale, label, color)
+	}
+	if m.message != "" {
+		h.drawCentered(s, screen, windowWidth/2, itemY(len(m.items)), 2, m.message, hudLabelColor)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import "testing"
+
+func TestPauseMenu(t *testing.T) {
+	h := newHarness(t, 1)
+	m := newMenus(h.g)
+
+	m.open(menuPause)
+	if !h.g.paused {
+		t.Fatal("game should be paused")
+	}
+	clock, pos := h.g.clock, h.level().monsters[0].transform.translation
+	h.script.hold(inputForward, 100)
+	h.run(100)
+	if h.g.clock != clock || h.level().monsters[0].transform.translation != pos {
+		t.Error("game should not advance while paused")
+	}
+
+	// selection wraps around, from resume to quit
+	m.move(-1)
+	if m.items[m.selected].label != "quit" {
+		t.Fatalf("unexpected selection %q", m.items[m.selected].label)
+	}
+	activate(t, m)
+	if m.screen() != menuQuit || m.items[m.selected].label != "no" {
+		t.Fatal("quit should be confirmed")
+	}
+	activate(t, m)
+	if m.quit || m.screen() != menuPause {
+		t.Fatal("quit has not been cancelled")
+	}
+
+	m.move(-1)
+	m.move(1)
+	activate(t, m)
+	if m.active() || h.g.paused {
+		t.Fatal("game should be resumed")
+	}
+	h.run(1)
+	if h.g.clock == clock {
+		t.Error("game should advance once resumed")
+	}
+
+	m.open(menuQuit)
+	m.move(1)
+	activate(t, m)
+	if !m.quit {
+		t.Error("quit has not been confirmed")
+	}
+}
+
+func TestMainMenu(t *testing.T) {
+	h := newHarness(t, 1)
+	m := newMenus(h.g)
+	m.open(menuMain)
+
+	m.back()
+	if !m.active() {
+		t.Fatal("main menu shown at start cannot be left")
+	}
+
+	defer func(speed int) { options.mouseSpeed = speed }(options.mouseSpeed)
+	options.mouseSpeed = maxMouseSpeed
+	m.selected = 2
+	activate(t, m)
+	if m.screen() != menuOptions {
+		t.Fatal("options should be shown")
+	}
+	m.change(1)
+	if options.mouseSpeed != minMouseSpeed || m.items[0].label != "mouse speed: 1" {
+		t.Errorf("unexpected mouse speed %d", options.mouseSpeed)
+	}
+	m.back()
+
+	h.player().health = 10
+	m.selected = 0
+	activate(t, m)
+	if m.active() || h.g.paused || h.player().health != defaultPlayer.maxHealth {
+		t.Error("new game should be started")
+	}
+}
+
+func TestMenuDisabledItems(t *testing.T) {
+	h := newDeathmatchHarness(t, 1)
+	m := newMenus(h.g)
+	m.open(menuPause)
+
+	// save and load are not available in deathmatch
+	m.move(1)
+	if m.items[m.selected].label != "options" {
+		t.Errorf("unexpected selection %q", m.items[m.selected].label)
+	}
+	m.selected = 1
+	activate(t, m)
+	if !m.active() {
+		t.Error("disabled item should do nothing")
+	}
+}
+
+func TestMenuItemAt(t *testing.T) {
+	h := newHarness(t, 1)
+	m := newMenus(h.g)
+	m.open(menuPause)
+
+	if i := m.itemAt(windowWidth/2, itemY(1)+1); i != 1 {
+		t.Errorf("expected item 1 but got %d", i)
+	}
+	if i := m.itemAt(10, itemY(1)+1); i != -1 {
+		t.Errorf("expected no item but got %d", i)
+	}
+	if i := m.itemAt(windowWidth/2, menuTitleY); i != -1 {
+		t.Errorf("expected no item on the title but got %d", i)
+	}
+}
+
+func activate(t *testing.T, m *menus) {
+	t.Helper()
+	err := m.activate()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
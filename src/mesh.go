@@ -27,6 +27,11 @@ type Mesh struct {
 
 func NewMesh(vertices []*Vertex, indices []int32, calcNormals bool) Mesh {
 	m := Mesh{}
+	if headless {
+		// null mesh, never drawn
+		m.size = int32(len(indices))
+		return m
+	}
 	m.initMeshData()
 	m.addVertices(vertices, indices, calcNormals)
 	return m
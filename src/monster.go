@@ -19,11 +19,21 @@ with this program; if not, write to the Free Software Foundation, Inc.,
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
 )
 
 const (
 	stateIdle = iota
+	statePatrol
 	stateChase
 	stateAttack
 	stateDying
@@ -34,79 +44,245 @@ const (
 	offsetFromGround     = 0.0 // -0.075
 	movementStopDistance = 1.5
 	shootAngle           = 10.0
-	attackChance         = 0.5
-)
+	// how far monsters can spot players
+	sightDistance = 1000.0
+	// patrolling monsters walk at this fraction of their speed, as in the original game
+	patrolSpeed = 1.0 / 3
 
-var (
-	monsterAnimationFrames = []string{
-		"SSWVA1.png",
-		"SSWVB1.png",
-		"SSWVC1.png",
-		"SSWVD1.png",
+	monsterDefsFile = "./res/monsters.json"
 
-		"SSWVE0.png",
-		"SSWVF0.png",
-		"SSWVG0.png",
+	// standing and walking monsters are seen from eight angles
+	numRotations = 8
+)
 
-		"SSWVH0.png",
+// rotations are the frames of a sprite seen from each angle, see rotation().
+type rotations [numRotations]int
+
+// monsterDef is a type of monster, as described in monsterDefsFile; monsters of each type
+// are placed in maps with their own special.
+type monsterDef struct {
+	Name    string `json:"name"`
+	Special string `json:"special"`
+
+	Health    int     `json:"health"`
+	MoveSpeed float32 `json:"moveSpeed"`
+	DamageMin int     `json:"damageMin"`
+	DamageMax int     `json:"damageMax"`
+	// chance of starting an attack during a second of chase
+	AttackChance  float32 `json:"attackChance"`
+	ShootDistance float32 `json:"shootDistance"`
+	// size used for collisions and for being hit, in map cells
+	Size float32 `json:"size"`
+	// height of the sprite in map cells, and ratio of its height to its width
+	Scale  float32 `json:"scale"`
+	Aspect float32 `json:"aspect"`
+
+	// walk and attack frames are spread over a second, the shot is fired on the attack frame FireFrame;
+	// stand and walk sprites named with rotation 1 (e.g. SSWVA1.png) are seen from eight angles
+	// when the sprites of all rotations exist, as in the original game
+	Stand     string         `json:"stand"`
+	Walk      []string       `json:"walk"`
+	Attack    []string       `json:"attack"`
+	FireFrame int            `json:"fireFrame"`
+	Dying     []monsterFrame `json:"dying"`
+	Dead      monsterFrame   `json:"dead"`
+
+	// names of the sound effects, not played until there is audio
+	Sounds map[string]string `json:"sounds,omitempty"`
+
+	special wolfmap.Special
+	mesh    Mesh
+	// textures of all frames without duplicates; frames are indexes in this slice
+	animations   []*Texture
+	stand, dead  int
+	walk, attack []int
+	// dying frames end at the corresponding time since death
+	dying    []int
+	dyingEnd []time.Duration
+	// rotations of each frame which has them
+	rotations map[int]*rotations
+}
 
-		"SSWVI0.png",
-		"SSWVJ0.png",
-		"SSWVK0.png",
-		"SSWVL0.png",
-		"SSWVM0.png",
-	}
-	_defaultMonster = Object{K: 1.9310344827586206896551724137931,
-		scale: 0.7,
+type monsterFrame struct {
+	Texture string `json:"texture"`
+	// milliseconds, for dying frames
+	Duration int        `json:"duration,omitempty"`
+	Scale    [3]float32 `json:"scale"`
+}
 
-		offsetX: 0.0, // 0.05
-		offsetY: 0.0, // 0.01
+type monsterDefsError struct {
+	fileName string
+	err      error
+}
 
-		moveSpeed: 2.0,
+func (mde monsterDefsError) Error() string {
+	return fmt.Sprintf("monsters(%s): %v", mde.fileName, mde.err)
+}
 
-		size: 0.2,
+var monsterDefs map[wolfmap.Special]*monsterDef
 
-		shootDistance: 1000.0,
+// loadMonsterDefs reads the monster types, loading their sprites.
+func loadMonsterDefs(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return monsterDefsError{fileName, err}
+	}
+	defer f.Close()
 
-		maxHealth: 100,
-		damageMin: 5,
-		damageMax: 30,
+	var defs []*monsterDef
+	err = json.NewDecoder(f).Decode(&defs)
+	if err != nil {
+		return monsterDefsError{fileName, err}
 	}
-	defaultMonsterSize = Vector2f{_defaultMonster.size, _defaultMonster.size}
-)
 
-func init() {
-	_defaultMonster.sizeY = _defaultMonster.scale
-	_defaultMonster.sizeX = _defaultMonster.sizeY / (_defaultMonster.K * 2)
-	_defaultMonster.texMinX = -_defaultMonster.offsetX
-	_defaultMonster.texMaxX = -1 - _defaultMonster.offsetX
-	_defaultMonster.texMinY = -_defaultMonster.offsetY
-	_defaultMonster.texMaxY = 1 - _defaultMonster.offsetY
+	monsterDefs = make(map[wolfmap.Special]*monsterDef, len(defs))
+	for _, def := range defs {
+		err = def.validate()
+		if err == nil && monsterDefs[def.special] != nil {
+			err = fmt.Errorf("duplicate special '%c'", def.special)
+		}
+		if err != nil {
+			return monsterDefsError{fileName, fmt.Errorf("%s: %v", def.Name, err)}
+		}
+		err = def.init()
+		if err != nil {
+			return err
+		}
+		monsterDefs[def.special] = def
+	}
+	return nil
+}
+
+func (def *monsterDef) validate() error {
+	if len(def.Special) != 1 || !wolfmap.Special(def.Special[0]).IsMonster() {
+		return fmt.Errorf("invalid special %q", def.Special)
+	}
+	def.special = wolfmap.Special(def.Special[0])
+
+	switch {
+	case def.Health <= 0:
+		return errors.New("health must be positive")
+	case def.DamageMin < 0 || def.DamageMax <= def.DamageMin:
+		return errors.New("invalid damage range")
+	case def.MoveSpeed <= 0 || def.Size <= 0 || def.Scale <= 0 || def.Aspect <= 0:
+		return errors.New("speed and sizes must be positive")
+	case def.Stand == "" || def.Dead.Texture == "" || len(def.Walk) == 0 || len(def.Dying) == 0:
+		return errors.New("missing frames")
+	case def.FireFrame < 0 || def.FireFrame >= len(def.Attack):
+		return fmt.Errorf("invalid fire frame %d", def.FireFrame)
+	}
+	return nil
 }
 
-func (m *Object) initMonster() error {
-	m.animations = make([]*Texture, len(monsterAnimationFrames))
-	for i := 0; i < len(monsterAnimationFrames); i++ {
-		var err error
-		m.animations[i], err = NewTexture(monsterAnimationFrames[i])
+// init loads the sprites and creates the mesh, sized after the sprites.
+func (def *monsterDef) init() error {
+	textures := map[string]int{}
+	frame := func(name string) (int, error) {
+		if i, ok := textures[name]; ok {
+			return i, nil
+		}
+		t, err := NewTexture(name)
+		if err != nil {
+			return 0, err
+		}
+		textures[name] = len(def.animations)
+		def.animations = append(def.animations, t)
+		return textures[name], nil
+	}
+	def.rotations = map[int]*rotations{}
+	rotated := func(name string) (int, error) {
+		i, err := frame(name)
+		if err != nil || def.rotations[i] != nil {
+			return i, err
+		}
+		set := &rotations{}
+		for r, name := range rotationNames(name, textureExists) {
+			set[r], err = frame(name)
+			if err != nil {
+				return 0, err
+			}
+		}
+		for _, j := range set {
+			def.rotations[j] = set
+		}
+		return i, nil
+	}
+
+	var err error
+	def.stand, err = rotated(def.Stand)
+	if err != nil {
+		return err
+	}
+	for _, frames := range []struct {
+		names   []string
+		indexes *[]int
+		load    func(string) (int, error)
+	}{{def.Walk, &def.walk, rotated}, {def.Attack, &def.attack, frame}} {
+		for _, name := range frames.names {
+			i, err := frames.load(name)
+			if err != nil {
+				return err
+			}
+			*frames.indexes = append(*frames.indexes, i)
+		}
+	}
+	var end time.Duration
+	for _, f := range def.Dying {
+		i, err := frame(f.Texture)
 		if err != nil {
 			return err
 		}
+		end += time.Duration(f.Duration) * time.Millisecond
+		def.dying = append(def.dying, i)
+		def.dyingEnd = append(def.dyingEnd, end)
+	}
+	def.dead, err = frame(def.Dead.Texture)
+	if err != nil {
+		return err
 	}
 
+	sizeY := def.Scale
+	sizeX := sizeY / (def.Aspect * 2)
 	vertices := []*Vertex{
-		&Vertex{Vector3f{-m.sizeX, m.start, m.start}, Vector2f{m.texMaxX, m.texMaxY}, Vector3f{0, 0, 0}},
-		&Vertex{Vector3f{-m.sizeX, m.sizeY, m.start}, Vector2f{m.texMaxX, m.texMinY}, Vector3f{0, 0, 0}},
-		&Vertex{Vector3f{m.sizeX, m.sizeY, m.start}, Vector2f{m.texMinX, m.texMinY}, Vector3f{0, 0, 0}},
-		&Vertex{Vector3f{m.sizeX, m.start, m.start}, Vector2f{m.texMinX, m.texMaxY}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{-sizeX, 0, 0}, Vector2f{-1, 1}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{-sizeX, sizeY, 0}, Vector2f{-1, 0}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{sizeX, sizeY, 0}, Vector2f{0, 0}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{sizeX, 0, 0}, Vector2f{0, 1}, Vector3f{0, 0, 0}},
 	}
 
 	indices := []int32{0, 1, 2, 0, 2, 3}
 
-	m.mesh = NewMesh(vertices, indices, false)
+	def.mesh = NewMesh(vertices, indices, false)
 	return nil
 }
 
+// rotationNames returns the sprites of all rotations of the specified one; when some of them
+// do not exist, the specified sprite is used for all angles.
+func rotationNames(name string, exists func(string) bool) []string {
+	names := make([]string, numRotations)
+	for r := range names {
+		names[r] = name
+	}
+	prefix := strings.TrimSuffix(name, "1.png")
+	if prefix == name {
+		return names
+	}
+	for r := 1; r < numRotations; r++ {
+		if !exists(prefix + strconv.Itoa(r+1) + ".png") {
+			return names
+		}
+	}
+	for r := 1; r < numRotations; r++ {
+		names[r] = prefix + strconv.Itoa(r+1) + ".png"
+	}
+	return names
+}
+
+// animationFrame returns the frame of an animation lasting a second, at the current time.
+func (m *Monster) animationFrame(frames []int) int {
+	return frames[int(m.game.getDecimals()*float32(len(frames)))%len(frames)]
+}
+
 type Monster struct {
 	transform  *Transform
 	state      int
@@ -114,101 +290,189 @@ type Monster struct {
 	canLook    bool
 	health     int
 	material   *Material
-	deathTime  time.Time
+	deathTime  time.Duration // game clock time
 	animations []*Texture
+	animFrame  int // index in animations of the current frame
 	mesh       Mesh
+	def        *monsterDef
+	// direction the monster is facing, on the ground plane
+	heading Vector3f
+	// monsters in ambush do not hear gunfire
+	ambush bool
+	// cell walked to while patrolling, see patrolUpdate
+	patrolGoal cell
+	// player chased and attacked, chosen on each update
+	target *Player
+	// route to the target, see chaseDirection
+	path     []cell
+	pathGoal cell
+	replanAt time.Duration
 
 	game *Game
 }
 
-func (g *Game) NewMonster(t *Transform, animations []*Texture) *Monster {
+func (g *Game) NewMonster(t *Transform, def *monsterDef) *Monster {
 	m := Monster{}
 
-	m.mesh = _defaultMonster.mesh
+	m.def = def
+	m.mesh = def.mesh
 	m.transform = t
 	m.game = g
 	m.state = stateIdle
-	m.health = _defaultMonster.maxHealth
-	m.animations = animations
-	m.material = NewMaterial(m.animations[0])
+	m.health = def.Health
+	m.heading = Vector3f{0, 0, 1}
+	m.patrolGoal = cellAt(t.translation)
+	m.animations = def.animations
+	m.animFrame = def.stand
+	m.material = NewMaterial(m.animations[def.stand])
 
 	return &m
 }
 
+// setActor applies the map annotations of the cell where the monster is placed.
+func (m *Monster) setActor(a wolfmap.Actor) {
+	if a.Facing != wolfmap.NoDirection {
+		m.heading = directionVector(a.Facing)
+	}
+	if a.Patrol {
+		m.state = statePatrol
+	}
+	m.ambush = a.Ambush
+}
+
+// directionVector returns the unit vector of a map direction.
+func directionVector(d wolfmap.Direction) Vector3f {
+	dx, dy := d.Delta()
+	return Vector3f{float32(dx), 0, float32(dy)}.normalised()
+}
+
+// unaware returns true when the monster has not yet spotted nor heard any player.
+func (m *Monster) unaware() bool {
+	return m.state == stateIdle || m.state == statePatrol
+}
+
 func (m *Monster) damage(amt int) {
-	if m.state == stateIdle {
+	if m.unaware() {
 		m.state = stateChase
 	}
 
 	m.health -= amt
 
 	if m.health <= 0 {
+		if m.alive() {
+			m.deathTime = m.game.clock
+			m.game.level.kills++
+		}
 		m.state = stateDying
 	}
 }
 
-func getDecimals() float32 {
-	now := time.Now()
-	ns := float32(now.UnixNano() - now.Unix()*1e9)
+func (m *Monster) alive() bool {
+	return m.state != stateDying && m.state != stateDead
+}
+
+// spot returns true when the target is in sight; monsters look for it once per second.
+func (m *Monster) spot(orientation Vector3f) bool {
+	if m.game.getDecimals() < 0.5 {
+		m.canLook = true
+		return false
+	}
+	if !m.canLook {
+		return false
+	}
+	m.canLook = false
+
+	lineStart := Vector2f{m.transform.translation.X, m.transform.translation.Z}
+	castDirection := Vector2f{orientation.X, orientation.Z}
+	lineEnd := lineStart.add(castDirection.mulf(sightDistance))
+
+	collisionVector := m.game.level.checkIntersections(lineStart, lineEnd, nil)
+	playerIntersectVector := Vector2f{m.target.camera.pos.X, m.target.camera.pos.Z}
 
-	return ns / float32(1e9)
+	return collisionVector == nil || playerIntersectVector.sub(lineStart).length() < collisionVector.sub(lineStart).length()
 }
 
 func (m *Monster) idleUpdate(orientation Vector3f, distance float32) {
-	if getDecimals() < 0.5 {
-		m.canLook = true
-		m.material.texture = m.animations[0]
-	} else {
-		if m.canLook {
-			lineStart := Vector2f{m.transform.translation.X, m.transform.translation.Z}
-			castDirection := Vector2f{orientation.X, orientation.Z}
-			lineEnd := lineStart.add(castDirection.mulf(_defaultMonster.shootDistance))
+	m.animFrame = m.def.stand
+	if m.spot(orientation) {
+		m.state = stateChase
+	}
+}
 
-			collisionVector := m.game.level.checkIntersections(lineStart, lineEnd, false)
-			playerIntersectVector := Vector2f{m.game.Camera().pos.X, m.game.Camera().pos.Z}
+// patrolUpdate walks from cell to cell in the direction faced until the target is spotted,
+// following the turn markers of the map and turning back at walls.
+func (m *Monster) patrolUpdate(orientation Vector3f, distance float32) error {
+	m.animFrame = m.animationFrame(m.def.walk)
+	if m.spot(orientation) {
+		m.state = stateChase
+		return nil
+	}
 
-			if collisionVector == nil || playerIntersectVector.sub(lineStart).length() < collisionVector.sub(lineStart).length() {
-				m.state = stateChase
-			}
+	pos := m.transform.translation
+	toGoal := m.patrolGoal.center().sub(Vector3f{pos.X, 0, pos.Z})
+	if toGoal.length() < waypointRadius {
+		m.patrolGoal = m.nextPatrolCell()
+		toGoal = m.patrolGoal.center().sub(Vector3f{pos.X, 0, pos.Z})
+		if toGoal.length() < waypointRadius {
+			// nowhere to go
+			return nil
+		}
+	}
+	direction := toGoal.normalised()
+
+	moveAmount := m.def.MoveSpeed * patrolSpeed * float32(m.game.timeDelta)
+	collisionVector := m.game.level.checkCollision(pos, pos.add(direction.mulf(moveAmount)), m.def.Size, m.def.Size)
+	movementVector := collisionVector.mul(direction)
+	if movementVector.length() > 0 {
+		m.transform.translation = pos.add(movementVector.mulf(moveAmount))
+	}
+	if movementVector.sub(direction).length() != 0 {
+		return m.game.level.openDoors(m.transform.translation, false)
+	}
+	return nil
+}
 
-			m.canLook = false
+// nextPatrolCell returns the cell to walk to after reaching the current patrol goal,
+// updating the heading with the turn marked there, if any.
+func (m *Monster) nextPatrolCell() cell {
+	l := m.game.level
+	from := m.patrolGoal
+	if turn := l.level.Actor(from.x, from.y).Turn; turn != wolfmap.NoDirection {
+		m.heading = directionVector(turn)
+	}
+	for i := 0; i < 2; i++ {
+		next := cell{from.x + int(math.Round(float64(m.heading.X))), from.y + int(math.Round(float64(m.heading.Z)))}
+		if l.paths.canStep(from, next) {
+			return next
 		}
+		m.heading = m.heading.mulf(-1)
 	}
+	return from
 }
 
 func (m *Monster) chaseUpdate(orientation Vector3f, distance float32) error {
-	timeDecimals := getDecimals()
-
-	var animFrame int
-	if timeDecimals < 0.25 {
-		animFrame = 0
-	} else if timeDecimals < 0.5 {
-		animFrame = 1
-	} else if timeDecimals < 0.75 {
-		animFrame = 2
-	} else {
-		animFrame = 3
-	}
-	m.material.texture = m.animations[animFrame]
+	m.animFrame = m.animationFrame(m.def.walk)
 
-	if random.Float32() < attackChance*float32(m.game.timeDelta) {
+	if m.game.random.Float32() < m.def.AttackChance*float32(m.game.timeDelta) {
 		m.state = stateAttack
 	}
 
 	if distance > movementStopDistance {
-		moveAmount := _defaultMonster.moveSpeed * float32(m.game.timeDelta)
+		moveAmount := m.def.MoveSpeed * float32(m.game.timeDelta)
+		direction := m.chaseDirection(orientation)
 
 		oldPos := m.transform.translation
-		newPos := m.transform.translation.add(orientation.mulf(moveAmount))
+		newPos := m.transform.translation.add(direction.mulf(moveAmount))
 
-		collisionVector := m.game.level.checkCollision(oldPos, newPos, _defaultMonster.size, _defaultMonster.size)
-		movementVector := collisionVector.mul(orientation)
+		collisionVector := m.game.level.checkCollision(oldPos, newPos, m.def.Size, m.def.Size)
+		movementVector := collisionVector.mul(direction)
 
 		if movementVector.length() > 0 {
 			m.transform.translation = m.transform.translation.add(movementVector.mulf(moveAmount))
+			m.face(movementVector)
 		}
 
-		if movementVector.sub(orientation).length() != 0 {
+		if movementVector.sub(direction).length() != 0 {
 			err := m.game.level.openDoors(m.transform.translation, false)
 			if err != nil {
 				return err
@@ -221,70 +485,76 @@ func (m *Monster) chaseUpdate(orientation Vector3f, distance float32) error {
 	return nil
 }
 
+// chaseDirection returns where to walk to reach the target: towards the next cell of the route
+// planned around walls and through doors, or straight to the target when there is no route.
+func (m *Monster) chaseDirection(orientation Vector3f) Vector3f {
+	pos := m.transform.translation
+	goal := cellAt(m.target.camera.pos)
+	if m.game.clock >= m.replanAt || (len(m.path) == 0 && goal != m.pathGoal) {
+		m.path, _ = m.game.level.paths.find(cellAt(pos), goal, m.game.clock)
+		m.pathGoal = goal
+		m.replanAt = m.game.clock + replanInterval
+	}
+
+	for len(m.path) > 0 {
+		toWaypoint := m.path[0].center().sub(Vector3f{pos.X, 0, pos.Z})
+		distance := toWaypoint.length()
+		if distance < waypointRadius {
+			m.path = m.path[1:]
+			continue
+		}
+		return toWaypoint.divf(distance)
+	}
+	return orientation
+}
+
 func (m *Monster) attackUpdate(orientation Vector3f, distance float32) {
-	timeDecimals := getDecimals()
-
-	if timeDecimals < 0.25 {
-		m.material.texture = m.animations[4]
-	} else if timeDecimals < 0.5 {
-		m.material.texture = m.animations[5]
-	} else if timeDecimals < 0.75 {
-		m.material.texture = m.animations[6]
-		if m.canAttack {
-			lineStart := Vector2f{m.transform.translation.X, m.transform.translation.Z}
-			castDirection := Vector2f{orientation.X, orientation.Z}.rotate((random.Float32() - 0.5) * shootAngle)
-			lineEnd := lineStart.add(castDirection.mulf(_defaultMonster.shootDistance))
-
-			collisionVector := m.game.level.checkIntersections(lineStart, lineEnd, false)
-
-			playerIntersectVector := lineIntersectRect(lineStart, lineEnd, Vector2f{m.game.Camera().pos.X, m.game.Camera().pos.Z}, Vector2f{defaultPlayer.size, defaultPlayer.size})
-			if playerIntersectVector != nil && (collisionVector == nil || playerIntersectVector.sub(lineStart).length() < collisionVector.sub(lineStart).length()) {
-				m.game.level.damagePlayer(_defaultMonster.damageMin + random.Intn(_defaultMonster.damageMax-_defaultMonster.damageMin))
-			}
+	m.face(orientation)
+	frame := int(m.game.getDecimals() * float32(len(m.def.attack)))
+	m.animFrame = m.def.attack[frame]
 
-			m.canAttack = false
+	if frame == m.def.FireFrame && m.canAttack {
+		lineStart := Vector2f{m.transform.translation.X, m.transform.translation.Z}
+		castDirection := Vector2f{orientation.X, orientation.Z}.rotate((m.game.random.Float32() - 0.5) * shootAngle)
+		lineEnd := lineStart.add(castDirection.mulf(m.def.ShootDistance))
+
+		collisionVector := m.game.level.checkIntersections(lineStart, lineEnd, nil)
+
+		playerIntersectVector := lineIntersectRect(lineStart, lineEnd, Vector2f{m.target.camera.pos.X, m.target.camera.pos.Z}, Vector2f{defaultPlayer.size, defaultPlayer.size})
+		if playerIntersectVector != nil && (collisionVector == nil || playerIntersectVector.sub(lineStart).length() < collisionVector.sub(lineStart).length()) {
+			m.target.damage(m.def.DamageMin + m.game.random.Intn(m.def.DamageMax-m.def.DamageMin))
 		}
-	} else {
-		m.material.texture = m.animations[5]
+
+		m.canAttack = false
+	}
+	// back to chase once the last frame is shown
+	if frame == len(m.def.attack)-1 {
 		m.state = stateChase
 		m.canAttack = true
 	}
 }
 
-const (
-	time1 = time.Duration(100) * time.Millisecond
-	time2 = time.Duration(300) * time.Millisecond
-	time3 = time.Duration(450) * time.Millisecond
-	time4 = time.Duration(600) * time.Millisecond
-)
-
 func (m *Monster) dyingUpdate(orientation Vector3f, distance float32) {
-	now := time.Now()
-
-	if m.deathTime.IsZero() {
-		m.deathTime = now
-	}
-
-	if now.Before(m.deathTime.Add(time1)) {
-		m.material.texture = m.animations[8]
-		m.transform.scale = Vector3f{1, 0.96428571428571428571428571428571, 1}
-	} else if now.Before(m.deathTime.Add(time2)) {
-		m.material.texture = m.animations[9]
-		m.transform.scale = Vector3f{1.7, 0.9, 1.0}
-	} else if now.Before(m.deathTime.Add(time3)) {
-		m.material.texture = m.animations[10]
-		m.transform.scale = Vector3f{1.7, 0.9, 1.0}
-	} else if now.Before(m.deathTime.Add(time4)) {
-		m.material.texture = m.animations[11]
-		m.transform.scale = Vector3f{1.7, 0.5, 1.0}
-	} else {
-		m.state = stateDead
+	elapsed := m.game.clock - m.deathTime
+
+	for i, end := range m.def.dyingEnd {
+		if elapsed < end {
+			m.animFrame = m.def.dying[i]
+			m.transform.scale = fromArray(m.def.Dying[i].Scale)
+			return
+		}
 	}
+	m.state = stateDead
 }
 
 func (m *Monster) deadUpdate(orientation Vector3f, distance float32) {
-	m.material.texture = m.animations[12]
-	m.transform.scale = Vector3f{1.7586206896551724137931034482759, 0.28571428571428571428571428571429, 1}
+	m.animFrame = m.def.dead
+	m.transform.scale = fromArray(m.def.Dead.Scale)
+}
+
+// frame returns the index of the current animation frame.
+func (m *Monster) frame() int {
+	return m.animFrame
 }
 
 func (m *Monster) alignWithGround() {
@@ -302,18 +572,26 @@ func (m *Monster) faceCamera(directionToCamera Vector3f) {
 }
 
 func (m *Monster) update() error {
-	directionToCamera := m.game.Camera().pos.sub(m.transform.translation)
+	m.target = m.game.level.targetPlayer(m.transform.translation)
+	if m.target == nil {
+		return nil
+	}
+	directionToTarget := m.target.camera.pos.sub(m.transform.translation)
 
-	distance := directionToCamera.length()
+	distance := directionToTarget.length()
 
-	orientation := directionToCamera.divf(distance)
+	orientation := directionToTarget.divf(distance)
 
 	m.alignWithGround()
-	m.faceCamera(orientation)
 
 	switch m.state {
 	case stateIdle:
 		m.idleUpdate(orientation, distance)
+	case statePatrol:
+		err := m.patrolUpdate(orientation, distance)
+		if err != nil {
+			return err
+		}
 	case stateChase:
 		err := m.chaseUpdate(orientation, distance)
 		if err != nil {
@@ -332,7 +610,37 @@ func (m *Monster) update() error {
 	return nil
 }
 
-func (m *Monster) render() {
-	m.game.level.shader.updateUniforms(m.transform.getProjectedTransformation(m.game.Camera()), m.material)
+// face turns the monster towards the specified direction.
+func (m *Monster) face(direction Vector3f) {
+	heading := Vector3f{direction.X, 0, direction.Z}
+	if heading.length() > 0 {
+		m.heading = heading.normalised()
+	}
+}
+
+// rotation returns which of the rotations of a sprite is seen by a viewer in the specified direction:
+// 0 when the monster faces the viewer, then by steps of 45 degrees as the viewer goes around
+// the monster from its heading towards its +90 degrees side (from X towards Z); 4 is seen from behind.
+func rotation(heading, toViewer Vector3f) int {
+	angle := math.Atan2(float64(heading.X*toViewer.Z-heading.Z*toViewer.X), float64(heading.X*toViewer.X+heading.Z*toViewer.Z))
+	r := int(math.Floor(angle/(2*math.Pi/numRotations) + 0.5))
+	return (r + numRotations) % numRotations
+}
+
+// viewTexture returns the texture of the current frame as seen by a viewer in the specified direction.
+func (m *Monster) viewTexture(toViewer Vector3f) *Texture {
+	if set, ok := m.def.rotations[m.animFrame]; ok {
+		return m.animations[set[rotation(m.heading, toViewer)]]
+	}
+	return m.animations[m.animFrame]
+}
+
+func (m *Monster) render(c *Camera) {
+	toCamera := c.pos.sub(m.transform.translation)
+	m.faceCamera(toCamera)
+	// the material is copied, the texture seen is not part of the state of the monster
+	material := *m.material
+	material.texture = m.viewTexture(toCamera)
+	m.game.level.shader.updateUniforms(m.transform.getProjectedTransformation(c), &material)
 	m.mesh.draw()
 }
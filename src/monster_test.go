@@ -0,0 +1,203 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+func TestMonsterDefs(t *testing.T) {
+	for _, special := range []wolfmap.Special{wolfmap.MonsterSpecial, wolfmap.DogSpecial, wolfmap.OfficerSpecial, wolfmap.BossSpecial} {
+		def := monsterDefs[special]
+		if def == nil {
+			t.Fatalf("no monster type for special '%c'", special)
+		}
+		if len(def.walk) != len(def.Walk) || len(def.attack) != len(def.Attack) || len(def.dyingEnd) != len(def.Dying) {
+			t.Errorf("%s: frames not loaded", def.Name)
+		}
+	}
+
+	guard := monsterDefs[wolfmap.MonsterSpecial]
+	// frames shared by different states are loaded once
+	if len(guard.animations) != 12 || guard.stand != guard.walk[0] || guard.attack[1] != guard.attack[3] {
+		t.Errorf("unexpected guard frames: %d textures", len(guard.animations))
+	}
+
+	for _, def := range []monsterDef{
+		{Special: "x", Health: 1, DamageMax: 1, MoveSpeed: 1, Size: 1, Scale: 1, Aspect: 1},
+		{Special: "D", Health: 0, DamageMax: 1, MoveSpeed: 1, Size: 1, Scale: 1, Aspect: 1},
+		{Special: "D", Health: 1, DamageMin: 5, DamageMax: 5, MoveSpeed: 1, Size: 1, Scale: 1, Aspect: 1},
+		{Special: "D", Health: 1, DamageMax: 1, MoveSpeed: 1, Size: 1, Scale: 1, Aspect: 1},
+		{Special: "D", Health: 1, DamageMax: 1, MoveSpeed: 1, Size: 1, Scale: 1, Aspect: 1, Stand: "a", Walk: []string{"a"},
+			Attack: []string{"a"}, FireFrame: 1, Dying: []monsterFrame{{Texture: "a"}}, Dead: monsterFrame{Texture: "a"}},
+	} {
+		if err := def.validate(); err == nil {
+			t.Errorf("%+v should not be valid", def)
+		}
+	}
+}
+
+func TestMonsterTypes(t *testing.T) {
+	h := newHarness(t, 1)
+	l := h.level()
+
+	err := l.addSpecial(wolfmap.DogSpecial, 13, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dog := l.monsters[len(l.monsters)-1]
+	if dog.def.Name != "dog" || dog.health != dog.def.Health {
+		t.Fatalf("unexpected monster %q with health %d", dog.def.Name, dog.health)
+	}
+
+	// dogs are faster than guards
+	guard := h.monsterAt(13, 28)
+	guard.transform.translation = dog.transform.translation
+	guard.state, dog.state = stateChase, stateChase
+	h.placePlayer(13, 11, towardsPlusZ)
+	h.player().health = 1000000
+	h.runFor(frameTime * 100)
+	if dog.transform.translation.Z >= guard.transform.translation.Z-0.3 {
+		t.Errorf("dog at %v should be ahead of guard at %v", dog.transform.translation, guard.transform.translation)
+	}
+}
+
+func TestMonsterRotations(t *testing.T) {
+	heading := Vector3f{0, 0, 1}
+	for _, c := range []struct {
+		toViewer Vector3f
+		rotation int
+	}{
+		{Vector3f{0, 0, 1}, 0},
+		{Vector3f{-1, 0, 1}, 1},
+		{Vector3f{-1, 0, 0}, 2},
+		{Vector3f{0, 0, -1}, 4},
+		{Vector3f{1, 0, 0}, 6},
+		{Vector3f{0.1, 0, 1}, 0},
+	} {
+		if r := rotation(heading, c.toViewer); r != c.rotation {
+			t.Errorf("viewer at %v: expected rotation %d, got %d", c.toViewer, c.rotation, r)
+		}
+	}
+
+	all := func(string) bool { return true }
+	names := rotationNames("SSWVA1.png", all)
+	if names[0] != "SSWVA1.png" || names[7] != "SSWVA8.png" {
+		t.Errorf("unexpected rotations %v", names)
+	}
+	// missing rotations fall back to the front sprite
+	for _, names := range [][]string{
+		rotationNames("SSWVA1.png", func(name string) bool { return name != "SSWVA5.png" }),
+		rotationNames("SSWVE0.png", all),
+	} {
+		for _, name := range names {
+			if name != names[0] {
+				t.Errorf("unexpected rotations %v", names)
+				break
+			}
+		}
+	}
+
+	h := newHarness(t, 1)
+	m := h.monsterAt(13, 28)
+	def := *m.def
+	set := &rotations{}
+	def.animations = make([]*Texture, numRotations)
+	def.rotations = map[int]*rotations{}
+	for r := range set {
+		set[r] = r
+		def.animations[r] = &Texture{}
+		def.rotations[r] = set
+	}
+	m.def, m.animations = &def, def.animations
+	m.animFrame = 0
+	m.heading = Vector3f{1, 0, 0}
+	if m.viewTexture(Vector3f{-1, 0, 0}) != def.animations[4] {
+		t.Error("monster should be seen from behind")
+	}
+	// the rotation seen does not change the frame of the monster
+	m.animFrame = 4
+	if m.viewTexture(Vector3f{1, 0, 0}) != def.animations[0] {
+		t.Error("monster should be seen from the front")
+	}
+	if m.frame() != 4 {
+		t.Errorf("viewing the monster changed its frame to %d", m.frame())
+	}
+}
+
+func TestMonsterHeading(t *testing.T) {
+	h := newHarness(t, 1)
+	m := h.monsterAt(13, 28)
+	if m.heading != (Vector3f{0, 0, 1}) {
+		t.Fatalf("unexpected initial heading %v", m.heading)
+	}
+	m.state = stateChase
+	h.placePlayer(13, 20, towardsPlusZ)
+	h.player().health = 1000000
+	h.runFor(frameTime * 50)
+	if m.heading.Z > -0.9 || m.heading.Y != 0 {
+		t.Errorf("monster chasing towards -Z should face it, heading %v", m.heading)
+	}
+}
+
+func TestMonsterPatrol(t *testing.T) {
+	h := newHarness(t, 1)
+	l := h.level()
+	m := h.monsterAt(13, 28)
+	l.monsters = []*Monster{m}
+	h.placePlayer(5, 6, towardsPlusZ)
+
+	// turn south at the end of the corridor, then back and forth until the wall
+	l.level.Actors = make([][]byte, l.level.Height)
+	for x := range l.level.Actors {
+		l.level.Actors[x] = []byte(strings.Repeat(" ", l.level.Width))
+	}
+	l.level.Actors[13][26] = '2'
+	m.setActor(wolfmap.Actor{Facing: wolfmap.West, Patrol: true})
+	if m.heading != (Vector3f{0, 0, -1}) {
+		t.Fatalf("unexpected heading %v", m.heading)
+	}
+
+	visited := []cell{cellAt(m.transform.translation)}
+	for i := 0; i < int(12*time.Second/frameTime); i++ {
+		h.run(1)
+		if c := cellAt(m.transform.translation); c != visited[len(visited)-1] {
+			visited = append(visited, c)
+		}
+	}
+	if m.state != statePatrol {
+		t.Fatalf("monster stopped patrolling, state %d", m.state)
+	}
+	expected := []cell{{13, 28}, {13, 27}, {13, 26}, {14, 26}, {15, 26}, {14, 26}, {13, 26}, {14, 26}}
+	if len(visited) < len(expected) || !reflect.DeepEqual(visited[:len(expected)], expected) {
+		t.Errorf("unexpected patrol route %v", visited)
+	}
+
+	// patrolling monsters chase the players they see
+	h.placePlayer(13, 20, towardsPlusZ)
+	h.runFor(time.Second)
+	if m.state == statePatrol {
+		t.Error("monster did not spot the player")
+	}
+}
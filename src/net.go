@@ -0,0 +1,428 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// network messages are UDP datagrams starting with a header followed by the message body,
+// all in little endian byte order:
+//
+//	join      client asks for a player slot
+//	welcome   server assigns the slot and describes the game to set up
+//	full      all player slots are taken
+//	input     client input, the last few frames are repeated to cover for lost datagrams
+//	snapshot  state of players, monsters, doors and pickups as simulated by the server
+const (
+	netMagic   = 0x5747
+	netVersion = 7
+
+	// max number of input frames sent in each input message
+	netInputRedundancy = 4
+	// max size of a datagram
+	netMaxPacketSize = 65507
+)
+
+type netMessageType uint8
+
+const (
+	netMsgJoin netMessageType = iota + 1
+	netMsgWelcome
+	netMsgFull
+	netMsgInput
+	netMsgSnapshot
+)
+
+type netError struct {
+	addr string
+	err  error
+}
+
+func (ne netError) Error() string {
+	return fmt.Sprintf("net(%s): %v", ne.addr, ne.err)
+}
+
+var errServerFull = errors.New("server is full")
+
+type netHeader struct {
+	Magic   uint16
+	Version uint8
+	Type    netMessageType
+}
+
+type netWelcomeMessage struct {
+	Player     uint8
+	NumPlayers uint8
+	Deathmatch uint8
+	Level      uint16
+	Seed       int64
+	MapName    [64]byte
+}
+
+type netInputFrame struct {
+	Seq         uint32
+	Buttons     inputButtons
+	Weapon      uint8
+	Forward, Up [3]float32
+}
+
+type netSnapshotHeader struct {
+	Tick  uint32
+	Ack   uint32 // last input frame of the recipient applied by the server
+	State uint8
+	// levels loaded by the server so far, it changes also when the level is restarted
+	Loads   uint16
+	Level   uint16
+	MapName [64]byte
+	// level statistics
+	Kills, Items, Secrets uint16
+	Elapsed               uint32 // ticks
+	NumPlayers            uint8
+	NumMonster            uint16
+	NumDoors              uint16
+	NumPickups            uint16
+}
+
+func (h *netSnapshotHeader) mapName() string {
+	return string(bytes.TrimRight(h.MapName[:], "\x00"))
+}
+
+type netPlayerState struct {
+	X, Z        float32
+	Forward, Up [3]float32
+	Health      int16
+	Weapon      uint8
+	Weapons     uint8 // bitmask of the owned weapons
+	Ammo        [numAmmoTypes]uint16
+	Frags       int16
+	HasShot     uint8
+	LastShot    uint32 // tick
+	HasDamage   uint8
+	LastDamage  uint32 // tick
+	Lives       int8
+	Score       int32
+}
+
+type netMonsterState struct {
+	X, Z           float32
+	State          uint8
+	Frame          uint8
+	ScaleX, ScaleY float32
+	// heading of the monster, for its rotation
+	HeadingX, HeadingZ float32
+}
+
+type netDoorState struct {
+	X, Z float32
+}
+
+func newNetMessage(t netMessageType) *bytes.Buffer {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, netHeader{netMagic, netVersion, t})
+	return &buf
+}
+
+// readNetMessage validates the header of a message and returns its type.
+func readNetMessage(r io.Reader) (netMessageType, error) {
+	var h netHeader
+	err := binary.Read(r, binary.LittleEndian, &h)
+	if err != nil {
+		return 0, err
+	}
+	if h.Magic != netMagic {
+		return 0, errors.New("not a WolfenGo message")
+	}
+	if h.Version != netVersion {
+		return 0, fmt.Errorf("unsupported protocol version %d", h.Version)
+	}
+	return h.Type, nil
+}
+
+func toArray(v Vector3f) [3]float32 {
+	return [3]float32{v.X, v.Y, v.Z}
+}
+
+func fromArray(a [3]float32) Vector3f {
+	return Vector3f{a[0], a[1], a[2]}
+}
+
+func encodeWelcome(player int, g *Game) ([]byte, error) {
+	w := netWelcomeMessage{
+		Player:     uint8(player),
+		NumPlayers: uint8(g.numPlayers()),
+		Level:      uint16(g.levelNum),
+		Seed:       g.seed,
+	}
+	if g.deathmatch {
+		w.Deathmatch = 1
+	}
+	if len(g.mapName) > len(w.MapName) {
+		return nil, fmt.Errorf("map name too long: %q", g.mapName)
+	}
+	copy(w.MapName[:], g.mapName)
+
+	buf := newNetMessage(netMsgWelcome)
+	binary.Write(buf, binary.LittleEndian, w)
+	return buf.Bytes(), nil
+}
+
+func (w *netWelcomeMessage) mapName() string {
+	return string(bytes.TrimRight(w.MapName[:], "\x00"))
+}
+
+// checkMapName rejects map names sent by a server that would load a file out of the res folder.
+func checkMapName(name string) error {
+	if name == "" || filepath.Base(name) != name || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid map name %q", name)
+	}
+	return nil
+}
+
+func encodeInput(frames []netInputFrame) []byte {
+	if len(frames) > netInputRedundancy {
+		frames = frames[len(frames)-netInputRedundancy:]
+	}
+	buf := newNetMessage(netMsgInput)
+	buf.WriteByte(uint8(len(frames)))
+	binary.Write(buf, binary.LittleEndian, frames)
+	return buf.Bytes()
+}
+
+func decodeInput(r io.Reader) ([]netInputFrame, error) {
+	var count uint8
+	err := binary.Read(r, binary.LittleEndian, &count)
+	if err != nil {
+		return nil, err
+	}
+	if count > netInputRedundancy {
+		return nil, fmt.Errorf("too many input frames: %d", count)
+	}
+	frames := make([]netInputFrame, count)
+	err = binary.Read(r, binary.LittleEndian, frames)
+	if err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+func (f netInputFrame) frame() inputFrame {
+	return inputFrame{
+		buttons:     f.Buttons,
+		weapon:      f.Weapon,
+		orientation: &orientation{fromArray(f.Forward), fromArray(f.Up)},
+	}
+}
+
+// encodeSnapshot writes the state of the game; ack is specific to the recipient.
+func encodeSnapshot(g *Game, ack uint32) []byte {
+	l := g.level
+	h := netSnapshotHeader{
+		Tick:       uint32(g.clock / frameTime),
+		Ack:        ack,
+		State:      uint8(g.state),
+		Loads:      uint16(g.levelLoads),
+		Level:      uint16(g.levelNum),
+		Kills:      uint16(l.kills),
+		Items:      uint16(l.items),
+		Secrets:    uint16(l.secretsFound),
+		Elapsed:    uint32(l.elapsed / frameTime),
+		NumPlayers: uint8(len(l.players)),
+		NumMonster: uint16(len(l.monsters)),
+		NumDoors:   uint16(len(l.doors)),
+		NumPickups: uint16(len(l.pickups)),
+	}
+	// map names longer than the welcome message allows are never loaded
+	copy(h.MapName[:], g.mapName)
+
+	buf := newNetMessage(netMsgSnapshot)
+	binary.Write(buf, binary.LittleEndian, h)
+	for _, p := range l.players {
+		s := netPlayerState{
+			X:          p.camera.pos.X,
+			Z:          p.camera.pos.Z,
+			Forward:    toArray(p.camera.forward),
+			Up:         toArray(p.camera.up),
+			Health:     int16(p.health),
+			Weapon:     uint8(p.weapon),
+			Frags:      int16(p.frags),
+			LastShot:   uint32(p.lastShot / frameTime),
+			LastDamage: uint32(p.lastDamage / frameTime),
+			Lives:      int8(p.lives),
+			Score:      int32(p.score),
+		}
+		for i, owned := range p.weapons {
+			if owned {
+				s.Weapons |= 1 << uint(i)
+			}
+		}
+		for i, amount := range p.ammo {
+			s.Ammo[i] = uint16(amount)
+		}
+		if p.hasShot {
+			s.HasShot = 1
+		}
+		if p.hasDamage {
+			s.HasDamage = 1
+		}
+		binary.Write(buf, binary.LittleEndian, s)
+	}
+	for _, m := range l.monsters {
+		binary.Write(buf, binary.LittleEndian, netMonsterState{
+			X:        m.transform.translation.X,
+			Z:        m.transform.translation.Z,
+			State:    uint8(m.state),
+			Frame:    uint8(m.frame()),
+			ScaleX:   m.transform.scale.X,
+			ScaleY:   m.transform.scale.Y,
+			HeadingX: m.heading.X,
+			HeadingZ: m.heading.Z,
+		})
+	}
+	for _, d := range l.doors {
+		binary.Write(buf, binary.LittleEndian, netDoorState{d.transform.translation.X, d.transform.translation.Z})
+	}
+	for _, p := range l.pickups {
+		binary.Write(buf, binary.LittleEndian, uint16(p.id))
+	}
+	return buf.Bytes()
+}
+
+// snapshot is a decoded snapshot message.
+type snapshot struct {
+	netSnapshotHeader
+	players  []netPlayerState
+	monsters []netMonsterState
+	doors    []netDoorState
+	pickups  []uint16
+}
+
+func decodeSnapshot(r *bytes.Reader) (*snapshot, error) {
+	var s snapshot
+	err := binary.Read(r, binary.LittleEndian, &s.netSnapshotHeader)
+	if err != nil {
+		return nil, err
+	}
+	// counts are checked before allocating, the level may change with this very snapshot
+	// thus they are bounded by the datagram length and compared to the level in apply
+	if s.NumPlayers == 0 || s.NumPlayers > maxPlayers {
+		return nil, fmt.Errorf("invalid number of players %d", s.NumPlayers)
+	}
+	size := int(s.NumPlayers)*binary.Size(netPlayerState{}) +
+		int(s.NumMonster)*binary.Size(netMonsterState{}) +
+		int(s.NumDoors)*binary.Size(netDoorState{}) +
+		int(s.NumPickups)*binary.Size(uint16(0))
+	if size != r.Len() {
+		return nil, fmt.Errorf("snapshot size mismatch: %d bytes expected, %d received", size, r.Len())
+	}
+	s.players = make([]netPlayerState, s.NumPlayers)
+	s.monsters = make([]netMonsterState, s.NumMonster)
+	s.doors = make([]netDoorState, s.NumDoors)
+	s.pickups = make([]uint16, s.NumPickups)
+	for _, data := range []interface{}{s.players, s.monsters, s.doors, s.pickups} {
+		err = binary.Read(r, binary.LittleEndian, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// apply sets the state of the level from the snapshot, except for the position of the player
+// controlled locally, which is predicted by the client.
+func (s *snapshot) apply(l *Level, localPlayer int) error {
+	if len(s.players) != len(l.players) || len(s.monsters) != len(l.monsters) || len(s.doors) != len(l.doors) ||
+		len(s.pickups) > l.totalItems {
+		return errors.New("snapshot does not match the level")
+	}
+	// snapshots come from the network, thus values used as indexes are checked before applying any
+	for _, ps := range s.players {
+		if weaponType(ps.Weapon) >= numWeapons {
+			return fmt.Errorf("invalid weapon %d", ps.Weapon)
+		}
+	}
+	for i, ms := range s.monsters {
+		if int(ms.Frame) >= len(l.monsters[i].animations) {
+			return fmt.Errorf("invalid monster animation frame %d", ms.Frame)
+		}
+		if int(ms.State) > stateDead {
+			return fmt.Errorf("invalid monster state %d", ms.State)
+		}
+	}
+
+	for i, ps := range s.players {
+		p := l.players[i]
+		if i != localPlayer {
+			p.camera.pos.X, p.camera.pos.Z = ps.X, ps.Z
+			p.camera.forward, p.camera.up = fromArray(ps.Forward), fromArray(ps.Up)
+		}
+		p.health = int(ps.Health)
+		p.weapon = weaponType(ps.Weapon)
+		p.frags = int(ps.Frags)
+		p.hasShot = ps.HasShot != 0
+		p.lastShot = time.Duration(ps.LastShot) * frameTime
+		p.hasDamage = ps.HasDamage != 0
+		p.lastDamage = time.Duration(ps.LastDamage) * frameTime
+		p.lives, p.score = int(ps.Lives), int(ps.Score)
+		for w := range p.weapons {
+			p.weapons[w] = ps.Weapons&(1<<uint(w)) != 0
+		}
+		for a := range p.ammo {
+			p.ammo[a] = int(ps.Ammo[a])
+		}
+	}
+
+	for i, ms := range s.monsters {
+		m := l.monsters[i]
+		m.transform.translation.X, m.transform.translation.Z = ms.X, ms.Z
+		m.state = int(ms.State)
+		m.animFrame = int(ms.Frame)
+		m.transform.scale.X, m.transform.scale.Y = ms.ScaleX, ms.ScaleY
+		m.heading = Vector3f{ms.HeadingX, 0, ms.HeadingZ}
+	}
+
+	l.kills, l.items, l.secretsFound = int(s.Kills), int(s.Items), int(s.Secrets)
+	l.elapsed = time.Duration(s.Elapsed) * frameTime
+
+	for i, ds := range s.doors {
+		d := l.doors[i]
+		d.transform.translation.X, d.transform.translation.Z = ds.X, ds.Z
+	}
+
+	present := make(map[uint16]bool, len(s.pickups))
+	for _, id := range s.pickups {
+		present[id] = true
+	}
+	pickups := l.pickups[:0]
+	for _, p := range l.pickups {
+		if present[uint16(p.id)] {
+			pickups = append(pickups, p)
+		}
+	}
+	l.pickups = pickups
+
+	return nil
+}
@@ -0,0 +1,215 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestDeathmatchFrag(t *testing.T) {
+	h := newDeathmatchHarness(t, 1)
+
+	if len(h.level().monsters) != 0 {
+		t.Fatal("there should be no monsters in deathmatch")
+	}
+
+	p1, p2 := h.player(), h.player2()
+	spawn := p2.camera.pos
+	movePlayer(p1, 9.5, 24.5, towardsPlusX)
+	movePlayer(p2, 10.5, 24.5, towardsPlusX)
+	// the pistol fires 4 times per second and up to 5 shots are needed
+	for i := 0; i < 375 && !p2.dead(); i++ {
+		h.script.hold(inputFire, 1).idle(1)
+		h.runScript()
+	}
+	if !p2.dead() {
+		t.Fatal("second player should have been killed")
+	}
+	if p1.frags != 1 {
+		t.Errorf("expected 1 frag but got %d", p1.frags)
+	}
+	if h.g.state != gamePlaying {
+		t.Fatal("deathmatch should go on after a kill")
+	}
+
+	h.runFor(respawnDelay + frameTime)
+	if p2.dead() || p2.health != defaultPlayer.maxHealth {
+		t.Fatalf("second player should have respawned, health is %d", p2.health)
+	}
+	if p2.camera.pos != spawn {
+		t.Errorf("second player respawned at %s instead of %s", p2.camera.pos.String(), spawn.String())
+	}
+}
+
+// TestNetworkGame plays over the loopback interface and checks that both the position predicted
+// by the client and the one simulated by the server match the one of a local game.
+func TestNetworkGame(t *testing.T) {
+	moves := func(s *script) *script {
+		return s.look(Vector2f{30, 0}).hold(inputForward|inputRight, 100)
+	}
+
+	local := newHarness(t, 1)
+	local.removeMonsters()
+	moves(local.script)
+	local.runScript()
+	expected := local.player().camera.pos
+
+	s, err := listenServer("127.0.0.1:0", 1, true, testSeed, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.run()
+	}()
+
+	in := moves(&script{})
+	steps := uint32(in.pending())
+	g, err := connect(s.conn.LocalAddr().String(), in)
+	if err != nil {
+		s.stop()
+		t.Fatal(err)
+	}
+	client := &harness{t: t, g: g, script: in}
+	start := client.player().camera.pos
+
+	// input is sent in real time, as the server would drop frames received too early
+	for in.pending() > 0 {
+		client.run(1)
+		time.Sleep(frameTime)
+	}
+	predicted := client.player().camera.pos
+	if predicted.sub(start).length() < 0.5 {
+		t.Errorf("client player did not move from %s", start.String())
+	}
+	if predicted.sub(expected).length() > 0.001 {
+		t.Errorf("client predicted %s instead of %s", predicted.String(), expected.String())
+	}
+
+	// wait for the server to acknowledge all moves
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		client.run(1)
+		if len(g.client.pending) == 0 || g.client.pending[0].Seq > steps {
+			break
+		}
+		time.Sleep(frameTime)
+	}
+	if len(g.client.pending) > 0 && g.client.pending[0].Seq <= steps {
+		t.Error("server did not acknowledge the client input")
+	}
+
+	err = g.shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+	err = s.stop()
+	if err != nil {
+		t.Error(err)
+	}
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulated := s.game.level.players[0].camera.pos
+	if simulated.sub(expected).length() > 0.001 {
+		t.Errorf("server simulated %s instead of %s", simulated.String(), expected.String())
+	}
+	if client.player().camera.pos.sub(simulated).length() > 0.001 {
+		t.Errorf("client is at %s but server at %s", client.player().camera.pos.String(), simulated.String())
+	}
+}
+
+func TestInvalidSnapshot(t *testing.T) {
+	h := newHarness(t, 1)
+	l := h.level()
+
+	for _, corrupt := range []func(s *snapshot){
+		func(s *snapshot) { s.players[0].Weapon = uint8(numWeapons) },
+		func(s *snapshot) { s.monsters[0].State = stateDead + 1 },
+		func(s *snapshot) { s.monsters[0].Frame = uint8(len(l.monsters[0].animations)) },
+	} {
+		r := bytes.NewReader(encodeSnapshot(h.g, 0))
+		_, err := readNetMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := decodeSnapshot(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupt(s)
+		if err := s.apply(l, 0); err == nil {
+			t.Error("expected an error for an invalid snapshot")
+		}
+		if h.player().weapon != pistolWeapon || l.monsters[0].state != stateIdle {
+			t.Error("invalid snapshot was partially applied")
+		}
+	}
+}
+
+func TestSnapshotCounts(t *testing.T) {
+	h := newHarness(t, 1)
+
+	for _, corrupt := range []func(hd *netSnapshotHeader){
+		func(hd *netSnapshotHeader) { hd.NumPlayers = maxPlayers + 1 },
+		func(hd *netSnapshotHeader) { hd.NumMonster = 0xffff },
+		func(hd *netSnapshotHeader) { hd.NumPickups++ },
+	} {
+		r := bytes.NewReader(encodeSnapshot(h.g, 0))
+		_, err := readNetMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hd netSnapshotHeader
+		err = binary.Read(r, binary.LittleEndian, &hd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupt(&hd)
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, &hd)
+		r.WriteTo(buf)
+
+		_, err = decodeSnapshot(bytes.NewReader(buf.Bytes()))
+		if err == nil {
+			t.Error("expected an error for invalid snapshot counts")
+		}
+	}
+}
+
+func TestCheckMapName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"level1.map":         true,
+		"":                   false,
+		"../level1.map":      false,
+		"/etc/passwd":        false,
+		"maps/level1.map":    false,
+		"..":                 false,
+		"level..map":         false,
+		`..\windows\win.ini`: false,
+	} {
+		if err := checkMapName(name); (err == nil) != valid {
+			t.Errorf("map name %q: valid is %v, error is %v", name, valid, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"container/heap"
+	"math"
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+const (
+	// cost of walking through a door cell, so that open corridors are preferred
+	doorPathCost = 3
+	diagonalCost = math.Sqrt2
+	// monsters plan their route again with this interval, following the target
+	replanInterval = 500 * time.Millisecond
+	// a waypoint is reached when the monster is this close to the center of its cell
+	waypointRadius = 0.1
+)
+
+// cell is a position on the map grid.
+type cell struct {
+	x, y int
+}
+
+func cellAt(pos Vector3f) cell {
+	return cell{int(pos.X / spotWidth), int(pos.Z / spotLength)}
+}
+
+// center returns the world position of the center of the cell, on the ground.
+func (c cell) center() Vector3f {
+	return Vector3f{(float32(c.x) + 0.5) * spotWidth, 0, (float32(c.y) + 0.5) * spotLength}
+}
+
+type pathKey struct {
+	from, to cell
+}
+
+// pathfinder finds routes on the map grid with A*, around walls and through doors;
+// routes found during a tick are cached, so that monsters going the same way share them.
+type pathfinder struct {
+	// x goes along the map rows, y along the columns
+	sizeX, sizeY int
+	// cost of entering each cell, 0 for walls
+	cost []float32
+
+	cache     map[pathKey][]cell
+	cacheTime time.Duration
+	// number of searches done, cached routes excluded
+	searches int
+}
+
+func newPathfinder(m *wolfmap.Map) *pathfinder {
+	pf := &pathfinder{sizeX: m.Height, sizeY: m.Width, cache: make(map[pathKey][]cell)}
+	pf.cost = make([]float32, pf.sizeX*pf.sizeY)
+	for x := 0; x < pf.sizeX; x++ {
+		for y := 0; y < pf.sizeY; y++ {
+			switch {
+			case m.IsEmpty(x, y):
+				continue
+			case m.Special(x, y) == wolfmap.DoorSpecial:
+				pf.cost[pf.index(cell{x, y})] = doorPathCost
+			default:
+				pf.cost[pf.index(cell{x, y})] = 1
+			}
+		}
+	}
+	return pf
+}
+
+func (pf *pathfinder) index(c cell) int {
+	return c.x*pf.sizeY + c.y
+}
+
+func (pf *pathfinder) walkable(c cell) bool {
+	return c.x >= 0 && c.y >= 0 && c.x < pf.sizeX && c.y < pf.sizeY && pf.cost[pf.index(c)] != 0
+}
+
+func (pf *pathfinder) isDoor(c cell) bool {
+	return pf.walkable(c) && pf.cost[pf.index(c)] == doorPathCost
+}
+
+// canStep returns true when the adjacent cell can be walked to: diagonal steps cannot cut
+// the corners of walls nor pass through doors.
+func (pf *pathfinder) canStep(from, to cell) bool {
+	if !pf.walkable(to) {
+		return false
+	}
+	if from.x != to.x && from.y != to.y {
+		return pf.walkable(cell{to.x, from.y}) && pf.walkable(cell{from.x, to.y}) && !pf.isDoor(from) && !pf.isDoor(to)
+	}
+	return true
+}
+
+// find returns the cells to walk through to go from one cell to the other, the first step
+// excluded and the destination included; ok is false when the destination cannot be reached.
+func (pf *pathfinder) find(from, to cell, now time.Duration) (path []cell, ok bool) {
+	if now != pf.cacheTime {
+		pf.cache = make(map[pathKey][]cell)
+		pf.cacheTime = now
+	}
+	key := pathKey{from, to}
+	if path, ok := pf.cache[key]; ok {
+		return path, path != nil
+	}
+
+	path = pf.search(from, to)
+	pf.cache[key] = path
+	return path, path != nil
+}
+
+// neighbours of a cell, the orthogonal ones first
+var neighbours = []struct {
+	dx, dy int
+	cost   float32
+}{
+	{1, 0, 1}, {-1, 0, 1}, {0, 1, 1}, {0, -1, 1},
+	{1, 1, diagonalCost}, {1, -1, diagonalCost}, {-1, 1, diagonalCost}, {-1, -1, diagonalCost},
+}
+
+// search runs A* on the grid; diagonal steps are allowed only when they do not cut
+// wall corners, and doors can only be walked through straight.
+func (pf *pathfinder) search(from, to cell) []cell {
+	pf.searches++
+	if !pf.walkable(from) || !pf.walkable(to) {
+		return nil
+	}
+	if from == to {
+		return []cell{}
+	}
+
+	n := pf.sizeX * pf.sizeY
+	score := make([]float32, n)
+	cameFrom := make([]int, n)
+	closed := make([]bool, n)
+	for i := range score {
+		score[i] = math.MaxFloat32
+		cameFrom[i] = -1
+	}
+
+	open := &pathQueue{}
+	start := pf.index(from)
+	score[start] = 0
+	heap.Push(open, pathNode{from, heuristic(from, to)})
+	for open.Len() > 0 {
+		current := heap.Pop(open).(pathNode).cell
+		i := pf.index(current)
+		if current == to {
+			return pf.route(cameFrom, start, i)
+		}
+		if closed[i] {
+			continue
+		}
+		closed[i] = true
+
+		for _, nb := range neighbours {
+			next := cell{current.x + nb.dx, current.y + nb.dy}
+			if !pf.canStep(current, next) {
+				continue
+			}
+			j := pf.index(next)
+			s := score[i] + nb.cost*pf.cost[j]
+			if s < score[j] {
+				score[j] = s
+				cameFrom[j] = i
+				heap.Push(open, pathNode{next, s + heuristic(next, to)})
+			}
+		}
+	}
+	return nil
+}
+
+// route walks back from the destination to the start.
+func (pf *pathfinder) route(cameFrom []int, start, end int) []cell {
+	var path []cell
+	for i := end; i != start; i = cameFrom[i] {
+		path = append(path, cell{i / pf.sizeY, i % pf.sizeY})
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// heuristic is the octile distance, which never overestimates the cost on the grid.
+func heuristic(a, b cell) float32 {
+	dx, dy := math.Abs(float64(a.x-b.x)), math.Abs(float64(a.y-b.y))
+	return float32(math.Max(dx, dy) + (diagonalCost-1)*math.Min(dx, dy))
+}
+
+type pathNode struct {
+	cell
+	priority float32
+}
+
+// pathQueue is the A* open set, ordered by priority.
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathNode)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
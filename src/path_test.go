@@ -0,0 +1,104 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindPath(t *testing.T) {
+	h := newHarness(t, 1)
+	pf := h.level().paths
+
+	from, to := cell{17, 18}, cell{10, 5}
+	path, ok := pf.find(from, to, h.g.clock)
+	if !ok {
+		t.Fatal("no path found")
+	}
+	if path[len(path)-1] != to {
+		t.Errorf("path ends at %v", path[len(path)-1])
+	}
+	previous := from
+	for _, c := range path {
+		dx, dy := c.x-previous.x, c.y-previous.y
+		if !pf.walkable(c) || dx < -1 || dx > 1 || dy < -1 || dy > 1 {
+			t.Fatalf("invalid step from %v to %v", previous, c)
+		}
+		if dx != 0 && dy != 0 && (!pf.walkable(cell{previous.x + dx, previous.y}) || !pf.walkable(cell{previous.x, previous.y + dy})) {
+			t.Fatalf("step from %v to %v cuts a corner", previous, c)
+		}
+		previous = c
+	}
+
+	if _, ok := pf.find(from, cell{0, 0}, h.g.clock); ok {
+		t.Error("walls cannot be reached")
+	}
+	if path, ok := pf.find(from, from, h.g.clock); !ok || len(path) != 0 {
+		t.Error("unexpected path to the same cell")
+	}
+}
+
+func TestPathThroughDoor(t *testing.T) {
+	h := newHarness(t, 1)
+
+	path, ok := h.level().paths.find(cell{11, 23}, cell{13, 23}, h.g.clock)
+	if !ok || len(path) != 2 || path[0] != (cell{12, 23}) {
+		t.Errorf("unexpected path %v", path)
+	}
+}
+
+func TestPathCache(t *testing.T) {
+	h := newHarness(t, 1)
+	pf := h.level().paths
+
+	pf.find(cell{17, 18}, cell{14, 16}, h.g.clock)
+	pf.find(cell{17, 18}, cell{14, 16}, h.g.clock)
+	if pf.searches != 1 {
+		t.Errorf("expected a single search but got %d", pf.searches)
+	}
+	pf.find(cell{17, 18}, cell{14, 16}, h.g.clock+frameTime)
+	if pf.searches != 2 {
+		t.Errorf("routes should be searched again on the next tick")
+	}
+}
+
+func TestMonsterChasesAroundWalls(t *testing.T) {
+	h := newHarness(t, 1)
+	m := h.monsterAt(17, 18)
+	h.level().monsters = []*Monster{m}
+	m.state = stateChase
+
+	// the player is just behind the wall, the way around passes through a door
+	h.placePlayer(13, 18, towardsPlusZ)
+	h.player().health = 1000000
+	reached := func() bool {
+		p := h.player().camera.pos
+		return p.sub(m.transform.translation).length() < movementStopDistance+0.5 && h.level().lineOfSight(m.transform.translation, p)
+	}
+	for i := 0; i < 30 && !reached(); i++ {
+		h.runFor(time.Second)
+	}
+	if !reached() {
+		t.Errorf("monster did not reach the player, stuck at %v", m.transform.translation)
+	}
+	if h.doorAt(19, 15).openingStartTime == 0 {
+		t.Error("monster did not open the door")
+	}
+}
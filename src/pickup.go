@@ -0,0 +1,154 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+const pickupDistance = 0.75
+
+type powerUpType int
+
+const (
+	noPowerUp powerUpType = iota
+	visorPowerUp
+)
+
+// pickupDef describes the effects of picking up an item; an item
+// is picked up only when at least one of its effects is applied.
+type pickupDef struct {
+	texture  string
+	material *Material
+
+	heal int
+	// numWeapons when no weapon is given
+	weapon     weaponType
+	ammo       ammoType
+	ammoAmount int
+
+	powerUp         powerUpType
+	powerUpDuration time.Duration
+}
+
+var (
+	_defaultPickup = Object{
+		K:     0.67857142857142857142857142857143,
+		scale: 0.25,
+	}
+
+	pickupDefs = map[wolfmap.Special]*pickupDef{
+		wolfmap.SmallMedkit: {texture: "MEDIA0.png", heal: 25, weapon: numWeapons},
+		// there is no art for the following items yet
+		wolfmap.BigMedkit:              {texture: "MEDIA0.png", heal: 50, weapon: numWeapons},
+		wolfmap.LightAmplificatorVisor: {texture: "MEDIA0.png", weapon: numWeapons, powerUp: visorPowerUp, powerUpDuration: 30 * time.Second},
+	}
+
+	// scene tint while the light amplification visor is active
+	visorTint = Vector3f{1.8, 1.8, 1.8}
+)
+
+func init() {
+	_defaultPickup.sizeY = _defaultPickup.scale
+	_defaultPickup.sizeX = _defaultPickup.sizeY / (_defaultPickup.K * 2.5)
+	_defaultPickup.texMinX = -_defaultPickup.offsetX
+	_defaultPickup.texMaxX = -1 - _defaultPickup.offsetX
+	_defaultPickup.texMinY = -_defaultPickup.offsetY
+	_defaultPickup.texMaxY = 1 - _defaultPickup.offsetY
+
+	// weapons come with their ammo
+	for w, def := range weaponDefs {
+		pickupDefs[def.special] = &pickupDef{texture: def.pickupTexture, weapon: weaponType(w), ammo: def.ammo, ammoAmount: def.pickupAmmo}
+	}
+	for a, def := range ammoDefs {
+		pickupDefs[def.special] = &pickupDef{texture: def.pickupTexture, weapon: numWeapons, ammo: ammoType(a), ammoAmount: def.pickupAmount}
+	}
+}
+
+func (m *Object) initPickups() error {
+	vertices := []*Vertex{
+		&Vertex{Vector3f{-m.sizeX, m.start, m.start}, Vector2f{m.texMaxX, m.texMaxY}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{-m.sizeX, m.sizeY, m.start}, Vector2f{m.texMaxX, m.texMinY}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{m.sizeX, m.sizeY, m.start}, Vector2f{m.texMinX, m.texMinY}, Vector3f{0, 0, 0}},
+		&Vertex{Vector3f{m.sizeX, m.start, m.start}, Vector2f{m.texMinX, m.texMaxY}, Vector3f{0, 0, 0}},
+	}
+
+	indices := []int32{0, 1, 2, 0, 2, 3}
+
+	m.mesh = NewMesh(vertices, indices, false)
+
+	// pickups share the same textures
+	materials := map[string]*Material{}
+	for _, def := range pickupDefs {
+		if mat, ok := materials[def.texture]; ok {
+			def.material = mat
+			continue
+		}
+
+		t, err := NewSpriteTexture(def.texture)
+		if err != nil {
+			return err
+		}
+		def.material = NewMaterial(t)
+		materials[def.texture] = def.material
+	}
+	return nil
+}
+
+type Pickup struct {
+	transform *Transform
+	mesh      Mesh
+	def       *pickupDef
+	// position in the level pickups when generated, used to identify it over the network
+	id   int
+	game *Game
+}
+
+func (g *Game) NewPickup(position Vector3f, def *pickupDef) *Pickup {
+	p := Pickup{}
+	p.game = g
+	p.def = def
+	p.mesh = _defaultPickup.mesh
+	p.transform = g.NewTransform()
+	p.transform.translation = position
+	return &p
+}
+
+func (p *Pickup) update() {
+	player := p.game.level.nearestPlayer(p.transform.translation, pickupDistance)
+	if player != nil && player.pickUp(p.def) {
+		p.game.level.removePickup(p)
+	}
+}
+
+func (p *Pickup) render(c *Camera) {
+	directionToCamera := c.pos.sub(p.transform.translation)
+
+	angleToFaceTheCamera := AtanAndToDegrees(directionToCamera.Z / directionToCamera.X)
+	if directionToCamera.X < 0 {
+		angleToFaceTheCamera += 180
+	}
+	p.transform.rotation.Y = angleToFaceTheCamera + 90
+
+	p.game.level.shader.updateUniforms(p.transform.getProjectedTransformation(c), p.def.material)
+	p.mesh.draw()
+}
@@ -0,0 +1,78 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+// addPickup places the item for the specified special at the center of a map cell.
+func (h *harness) addPickup(x, y int, special wolfmap.Special) {
+	h.t.Helper()
+	def, ok := pickupDefs[special]
+	if !ok {
+		h.t.Fatalf("no pickup for special '%c'", special)
+	}
+	h.g.level.pickups = append(h.g.level.pickups, h.g.NewPickup(Vector3f{float32(x) + 0.5, 0, float32(y) + 0.5}, def))
+}
+
+func TestBigMedkit(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+
+	h.player().health = 10
+	h.placePlayer(9, 24, towardsPlusZ)
+	h.addPickup(9, 24, wolfmap.BigMedkit)
+	h.run(1)
+
+	if expected := 10 + pickupDefs[wolfmap.BigMedkit].heal; h.player().health != expected {
+		t.Errorf("expected health %d but got %d", expected, h.player().health)
+	}
+}
+
+func TestVisorBrightensScene(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+
+	p := h.player()
+	if p.tint() != (Vector3f{1, 1, 1}) {
+		t.Fatal("scene should not be tinted without visor")
+	}
+
+	// the visor is always picked up, even at full health
+	h.placePlayer(9, 24, towardsPlusZ)
+	h.addPickup(9, 24, wolfmap.LightAmplificatorVisor)
+	h.run(1)
+	if len(h.level().pickups) != 0 {
+		t.Fatal("visor was not picked up")
+	}
+	if p.tint() != visorTint {
+		t.Error("scene should be brightened by the visor")
+	}
+
+	h.runFor(pickupDefs[wolfmap.LightAmplificatorVisor].powerUpDuration)
+	if p.tint() != (Vector3f{1, 1, 1}) {
+		t.Error("visor should have worn off")
+	}
+}
@@ -19,26 +19,53 @@ with this program; if not, write to the Free Software Foundation, Inc.,
 package main
 
 import (
-	"fmt"
-	"math/rand"
-
-	"github.com/go-gl/glfw/v3.1/glfw"
+	"time"
 )
 
 const (
 	gunOffset              = -0.0875
 	playerMouseSensitivity = 0.2
+	// time before a player killed in deathmatch is back
+	respawnDelay = 3 * time.Second
+	// extra lives at the start of the game
+	startLives = 3
+	// score for each monster killed
+	killScore = 100
 )
 
 type Player struct {
-	mesh        Mesh
-	gunMaterial *Material
+	mesh Mesh
 
 	gunTransform   *Transform
 	camera         *Camera
 	health         int
 	movementVector Vector3f
 
+	weapons [numWeapons]bool
+	ammo    [numAmmoTypes]int
+	weapon  weaponType
+	// game clock time at which the current weapon can fire again
+	nextShot time.Duration
+	// game clock time of the last shot, for the firing animation
+	lastShot    time.Duration
+	hasShot     bool
+	triggerHeld bool
+
+	// game clock time at which the light amplification visor wears off
+	visorEnd time.Duration
+
+	// game clock time of the last damage taken, for the HUD face
+	lastDamage time.Duration
+	hasDamage  bool
+
+	// carried over from level to level; lives are negative once the last one is lost
+	lives, score int
+
+	// deathmatch only
+	spawn     Vector3f
+	respawnAt time.Duration
+	frags     int
+
 	game *Game
 }
 
@@ -46,8 +73,6 @@ var (
 	defaultPlayer = Object{
 		K:         1.0379746835443037974683544303797,
 		scale:     0.0625,
-		damageMin: 20,
-		damageMax: 60,
 		maxHealth: 100,
 		// player is slightly faster than monsters
 		moveSpeed: 2.5,
@@ -55,8 +80,6 @@ var (
 		size:          0.2,
 		shootDistance: 1000.0,
 	}
-
-	defaultGunMaterial *Material
 )
 
 func init() {
@@ -81,44 +104,150 @@ func initPlayer() {
 	defaultPlayer.mesh = NewMesh(vertices, indices, false)
 }
 
-func initGun() error {
-	t, err := NewTexture("PISGB0.png")
-	if err != nil {
-		return err
-	}
-	defaultGunMaterial = NewMaterial(t)
-	return nil
-}
-
-func (g *Game) NewPlayer(position Vector3f, playerMesh Mesh, gunMaterial *Material) *Player {
+func (g *Game) NewPlayer(position Vector3f, playerMesh Mesh) *Player {
 	p := Player{}
 	p.game = g
 	p.mesh = playerMesh
-	p.gunMaterial = gunMaterial
 	p.camera = NewCamera(position, Vector3f{0, 0, -1}, Vector3f{0, 1, 0}, playerMouseSensitivity)
 	p.health = defaultPlayer.maxHealth
 	p.gunTransform = g.NewTransform()
 	p.gunTransform.translation = Vector3f{7, 0, 7}
+	p.spawn = position
+	p.lives = startLives
+	p.resetInventory()
 
 	return &p
 }
 
+func (p *Player) resetInventory() {
+	p.weapons = [numWeapons]bool{pistolWeapon: true}
+	p.ammo = [numAmmoTypes]int{pistolAmmo: weaponDefs[pistolWeapon].pickupAmmo}
+	p.weapon = pistolWeapon
+	p.hasShot = false
+}
+
+// respawn brings back a player killed in deathmatch to the start position, with the initial inventory.
+func (p *Player) respawn() {
+	p.health = defaultPlayer.maxHealth
+	p.camera.pos = p.spawn
+	p.resetInventory()
+}
+
 func (p *Player) damage(amt int) {
+	wasAlive := !p.dead()
 	p.health -= amt
+	if amt > 0 {
+		p.lastDamage, p.hasDamage = p.game.clock, true
+	}
 
 	// as this function is used to give health too, check for maximum overflow
 	if p.health > defaultPlayer.maxHealth {
 		p.health = defaultPlayer.maxHealth
-	} else if p.health <= 0 {
-		p.game.isRunning = false
-		fmt.Println("You just died! GAME OVER")
+	} else if p.health <= 0 && wasAlive {
+		if p.game.deathmatch {
+			p.respawnAt = p.game.clock + respawnDelay
+		} else {
+			p.lives--
+			if p.game.level.allPlayersDead() {
+				p.game.setState(gameOver)
+			}
+		}
 	}
-	// this println was in original clone
-	fmt.Println("player health =", p.health)
 }
 
-func getPlayerDamage() int {
-	return rand.Intn(defaultPlayer.damageMax-defaultPlayer.damageMin) + defaultPlayer.damageMin
+func (p *Player) dead() bool {
+	return p.health <= 0
+}
+
+func (p *Player) getDamage() int {
+	def := &weaponDefs[p.weapon]
+	return p.game.random.Intn(def.damageMax-def.damageMin) + def.damageMin
+}
+
+// pickUp applies the effects of an item, returning false when none could be applied.
+func (p *Player) pickUp(def *pickupDef) bool {
+	var picked bool
+	if def.heal > 0 && p.health < defaultPlayer.maxHealth {
+		p.damage(-def.heal)
+		picked = true
+	}
+	if def.weapon != numWeapons {
+		picked = p.giveWeapon(def.weapon) || picked
+	} else if def.ammoAmount > 0 {
+		picked = p.giveAmmo(def.ammo, def.ammoAmount) || picked
+	}
+	switch def.powerUp {
+	case visorPowerUp:
+		p.visorEnd = p.game.clock + def.powerUpDuration
+		picked = true
+	}
+	return picked
+}
+
+// tint returns the color by which the whole scene is multiplied.
+func (p *Player) tint() Vector3f {
+	if p.game.clock < p.visorEnd {
+		return visorTint
+	}
+	return Vector3f{1, 1, 1}
+}
+
+// giveWeapon adds the weapon and its ammo to the inventory, switching to it if it is new;
+// it returns false when neither were needed.
+func (p *Player) giveWeapon(w weaponType) bool {
+	def := &weaponDefs[w]
+	if p.weapons[w] {
+		return p.giveAmmo(def.ammo, def.pickupAmmo)
+	}
+
+	p.weapons[w] = true
+	p.giveAmmo(def.ammo, def.pickupAmmo)
+	p.selectWeapon(w)
+	return true
+}
+
+// giveAmmo returns false when the player already carries the maximum amount.
+func (p *Player) giveAmmo(a ammoType, amount int) bool {
+	max := ammoDefs[a].max
+	if p.ammo[a] >= max {
+		return false
+	}
+
+	p.ammo[a] += amount
+	if p.ammo[a] > max {
+		p.ammo[a] = max
+	}
+	return true
+}
+
+func (p *Player) selectWeapon(w weaponType) {
+	if w < 0 || w >= numWeapons || !p.weapons[w] || w == p.weapon {
+		return
+	}
+	p.weapon = w
+	p.hasShot = false
+}
+
+// fire shoots a bullet with the current weapon, if loaded and ready.
+func (p *Player) fire() {
+	def := &weaponDefs[p.weapon]
+	if p.game.clock < p.nextShot || p.ammo[def.ammo] == 0 {
+		return
+	}
+	p.ammo[def.ammo]--
+	p.nextShot = p.game.clock + def.fireInterval
+	p.lastShot = p.game.clock
+	p.hasShot = true
+
+	lineStart := Vector2f{p.camera.pos.X, p.camera.pos.Z}
+	castDirection := Vector2f{p.camera.forward.X, p.camera.forward.Z}.normalised()
+	if def.spread > 0 {
+		castDirection = castDirection.rotate((p.game.random.Float32()*2 - 1) * def.spread)
+	}
+	lineEnd := lineStart.add(castDirection.mulf(defaultPlayer.shootDistance))
+
+	p.game.level.checkIntersections(lineStart, lineEnd, p)
+	p.game.level.alert(p.camera.pos)
 }
 
 func (p *Player) update() {
@@ -151,59 +280,79 @@ func (p *Player) update() {
 	p.gunTransform.rotation.Y = angleToFaceTheCamera + 90
 }
 
-func (p *Player) input() error {
-	if Window.GetKey(glfw.KeyE) == glfw.Press {
+func (p *Player) input(in inputFrame) error {
+	if in.pressed(inputUse) {
 		err := p.game.level.openDoors(p.camera.pos, true)
 		if err != nil {
 			return err
 		}
 	}
 
-	if Window.GetKey(glfw.KeyEscape) == glfw.Press {
-		Window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
-		p.game.UnlockMouse()
+	if in.weapon != 0 {
+		p.selectWeapon(weaponType(in.weapon - 1))
 	}
 
-	// wait for left mouse click to lock the camera to the mouse
-	if Window.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press {
-		if !p.game.mouseLocked {
-			Window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
-			p.game.LockMouse()
-		} else {
-			// shoot a bullet
-			lineStart := Vector2f{p.camera.pos.X, p.camera.pos.Z}
-			castDirection := Vector2f{p.camera.forward.X, p.camera.forward.Z}.normalised()
-			lineEnd := lineStart.add(castDirection.mulf(defaultPlayer.shootDistance))
+	// semi-automatic weapons need the trigger to be released between shots
+	trigger := in.pressed(inputFire)
+	if trigger && (weaponDefs[p.weapon].automatic || !p.triggerHeld) {
+		p.fire()
+	}
+	p.triggerHeld = trigger
 
-			p.game.level.checkIntersections(lineStart, lineEnd, true)
-		}
+	p.steer(in)
+
+	return nil
+}
+
+// steer sets the player orientation and movement for the next update, without any other action;
+// it is also used by network clients to predict the player movement.
+func (p *Player) steer(in inputFrame) {
+	if in.orientation != nil {
+		p.camera.forward, p.camera.up = in.orientation.forward, in.orientation.up
 	}
 
 	p.movementVector = Vector3f{0, 0, 0}
 
-	if Window.GetKey(glfw.KeyW) == glfw.Press {
+	if in.pressed(inputForward) {
 		p.movementVector = p.movementVector.add(p.camera.forward)
 	}
-	if Window.GetKey(glfw.KeyS) == glfw.Press {
+	if in.pressed(inputBack) {
 		p.movementVector = p.movementVector.sub(p.camera.forward)
 	}
-	if Window.GetKey(glfw.KeyA) == glfw.Press {
+	if in.pressed(inputLeft) {
 		p.movementVector = p.movementVector.add(p.camera.getLeft())
 	}
-	if Window.GetKey(glfw.KeyD) == glfw.Press {
+	if in.pressed(inputRight) {
 		p.movementVector = p.movementVector.add(p.camera.getRight())
 	}
-	if Window.GetKey(glfw.KeyQ) == glfw.Press {
-		Window.SetShouldClose(true)
+	p.camera.mouseLook(in.mouseDelta)
+}
+
+// fireFrame returns the frame of the firing animation shown, -1 when the weapon is idle.
+func (p *Player) fireFrame() int {
+	if !p.hasShot {
+		return -1
 	}
-	if p.game.mouseLocked {
-		p.camera.mouseLook(&p.game.oldPosition)
+	elapsed := p.game.clock - p.lastShot
+	for i, f := range weaponDefs[p.weapon].fireFrames {
+		if elapsed < f.duration {
+			return i
+		}
+		elapsed -= f.duration
 	}
+	return -1
+}
 
-	return nil
+// viewMaterial returns the current frame of the weapon view sprite.
+func (p *Player) viewMaterial() *Material {
+	def := &weaponDefs[p.weapon]
+	if frame := p.fireFrame(); frame != -1 {
+		return def.fireMaterials[frame]
+	}
+	return def.material
 }
 
 func (p *Player) render() {
-	p.game.level.shader.updateUniforms(p.gunTransform.getProjectedTransformation(p.camera), p.gunMaterial)
+	p.game.level.shader.updateUniforms(p.gunTransform.getProjectedTransformation(p.camera), p.viewMaterial())
 	p.mesh.draw()
 }
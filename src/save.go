@@ -0,0 +1,326 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// saved games are JSON files; loading rebuilds the level from its map, then applies the saved state.
+const (
+	saveMagic   = "WSAVE"
+	saveVersion = 5
+
+	quickSaveFile = "quicksave.wsave"
+)
+
+type saveError struct {
+	fileName string
+	err      error
+}
+
+func (se saveError) Error() string {
+	return fmt.Sprintf("savegame(%s): %v", se.fileName, se.err)
+}
+
+type savedGame struct {
+	Magic   string
+	Version int
+
+	Level      uint
+	Map        string
+	StartLevel uint
+	StartMap   string
+	Seed       int64
+	// number of values drawn from the random stream
+	RandomDraws uint64
+	Clock       time.Duration
+	State       gameState
+
+	Elapsed time.Duration
+	Kills   int
+	// indexes of the secrets found
+	Secrets []int
+	// ids of the pickups collected
+	Collected []int
+
+	Players  []savedPlayer
+	Monsters []savedMonster
+	Doors    []savedDoor
+}
+
+type savedPlayer struct {
+	Position, Forward, Up Vector3f
+	Health                int
+	Weapons               [numWeapons]bool
+	Ammo                  [numAmmoTypes]int
+	Weapon                weaponType
+	NextShot, VisorEnd    time.Duration
+	TriggerHeld           bool
+	HasShot, HasDamage    bool
+	LastShot, LastDamage  time.Duration
+	Lives, Score          int
+}
+
+type savedMonster struct {
+	Position, Scale    Vector3f
+	Heading            Vector3f
+	PatrolGoal         [2]int
+	State, Health      int
+	Frame              int
+	DeathTime          time.Duration
+	CanAttack, CanLook bool
+}
+
+type savedDoor struct {
+	Position                                                Vector3f
+	Opening                                                 bool
+	OpeningStartTime, OpenTime, ClosingStartTime, CloseTime time.Duration
+}
+
+// save writes the state of the game to the specified file.
+func (g *Game) save(fileName string) error {
+	if g.client != nil || g.deathmatch {
+		return saveError{fileName, errors.New("network and deathmatch games cannot be saved")}
+	}
+
+	l := g.level
+	s := savedGame{
+		Magic:       saveMagic,
+		Version:     saveVersion,
+		Level:       g.levelNum,
+		Map:         g.mapName,
+		StartLevel:  g.startLevel,
+		StartMap:    g.startMap,
+		Seed:        g.seed,
+		RandomDraws: g.randomSource.draws,
+		Clock:       g.clock,
+		State:       g.state,
+		Elapsed:     l.elapsed,
+		Kills:       l.kills,
+	}
+	for i, secret := range l.secrets {
+		if secret.found {
+			s.Secrets = append(s.Secrets, i)
+		}
+	}
+	present := make(map[int]bool, len(l.pickups))
+	for _, p := range l.pickups {
+		present[p.id] = true
+	}
+	for id := 0; id < l.totalItems; id++ {
+		if !present[id] {
+			s.Collected = append(s.Collected, id)
+		}
+	}
+
+	for _, p := range l.players {
+		s.Players = append(s.Players, savedPlayer{
+			Position:    p.camera.pos,
+			Forward:     p.camera.forward,
+			Up:          p.camera.up,
+			Health:      p.health,
+			Weapons:     p.weapons,
+			Ammo:        p.ammo,
+			Weapon:      p.weapon,
+			NextShot:    p.nextShot,
+			VisorEnd:    p.visorEnd,
+			TriggerHeld: p.triggerHeld,
+			HasShot:     p.hasShot,
+			LastShot:    p.lastShot,
+			HasDamage:   p.hasDamage,
+			LastDamage:  p.lastDamage,
+			Lives:       p.lives,
+			Score:       p.score,
+		})
+	}
+	for _, m := range l.monsters {
+		s.Monsters = append(s.Monsters, savedMonster{
+			Position:   m.transform.translation,
+			Scale:      m.transform.scale,
+			Heading:    m.heading,
+			PatrolGoal: [2]int{m.patrolGoal.x, m.patrolGoal.y},
+			State:      m.state,
+			Health:     m.health,
+			Frame:      m.frame(),
+			DeathTime:  m.deathTime,
+			CanAttack:  m.canAttack,
+			CanLook:    m.canLook,
+		})
+	}
+	for _, d := range l.doors {
+		s.Doors = append(s.Doors, savedDoor{
+			Position:         d.transform.translation,
+			Opening:          d.isOpening,
+			OpeningStartTime: d.openingStartTime,
+			OpenTime:         d.openTime,
+			ClosingStartTime: d.closingStartTime,
+			CloseTime:        d.closeTime,
+		})
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return saveError{fileName, err}
+	}
+	err = json.NewEncoder(f).Encode(s)
+	if err != nil {
+		f.Close()
+		return saveError{fileName, err}
+	}
+	err = f.Close()
+	if err != nil {
+		return saveError{fileName, err}
+	}
+
+	fmt.Println("game saved to", fileName)
+	return nil
+}
+
+// load restores the game saved to the specified file.
+func (g *Game) load(fileName string) error {
+	if g.client != nil || g.deathmatch {
+		return saveError{fileName, errors.New("network and deathmatch games cannot be loaded")}
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return saveError{fileName, err}
+	}
+	var s savedGame
+	err = json.NewDecoder(f).Decode(&s)
+	f.Close()
+	if err != nil {
+		return saveError{fileName, err}
+	}
+	if s.Magic != saveMagic {
+		return saveError{fileName, errors.New("not a saved game")}
+	}
+	if s.Version != saveVersion {
+		return saveError{fileName, fmt.Errorf("unsupported version %d", s.Version)}
+	}
+	if len(s.Players) != g.numPlayers() {
+		return saveError{fileName, fmt.Errorf("saved game has %d players instead of %d", len(s.Players), g.numPlayers())}
+	}
+	if s.State < gamePlaying || s.State > gameVictory {
+		return saveError{fileName, fmt.Errorf("invalid game state %d", s.State)}
+	}
+
+	// players start anew, their lives and score are restored below
+	previous, previousNum, previousMap := g.level, g.levelNum, g.mapName
+	previousLoads, previousState := g.levelLoads, g.state
+	err = g.loadLevel(s.Map, s.Level, nil)
+	if err == nil {
+		err = s.apply(g.level)
+	}
+	if err != nil {
+		// keep playing the current level
+		g.level, g.levelNum, g.mapName = previous, previousNum, previousMap
+		g.levelLoads = previousLoads
+		if g.state != previousState {
+			g.setState(previousState)
+		}
+		return saveError{fileName, err}
+	}
+
+	g.startLevel, g.startMap = s.StartLevel, s.StartMap
+	g.clock = s.Clock
+	g.seedRandom(s.Seed, s.RandomDraws)
+	g.setState(s.State)
+
+	fmt.Println("game loaded from", fileName)
+	return nil
+}
+
+// apply sets the saved state on the level, just rebuilt from its map.
+func (s *savedGame) apply(l *Level) error {
+	if len(s.Monsters) != len(l.monsters) || len(s.Doors) != len(l.doors) {
+		return errors.New("saved game does not match the level map")
+	}
+
+	l.elapsed, l.kills = s.Elapsed, s.Kills
+	for _, i := range s.Secrets {
+		if i < 0 || i >= len(l.secrets) {
+			return fmt.Errorf("invalid secret %d", i)
+		}
+		l.secrets[i].found = true
+		l.secretsFound++
+	}
+	collected := make(map[int]bool, len(s.Collected))
+	for _, id := range s.Collected {
+		collected[id] = true
+	}
+	pickups := l.pickups[:0]
+	for _, p := range l.pickups {
+		if collected[p.id] {
+			l.items++
+		} else {
+			pickups = append(pickups, p)
+		}
+	}
+	l.pickups = pickups
+
+	for i, sp := range s.Players {
+		p := l.players[i]
+		if sp.Weapon < 0 || sp.Weapon >= numWeapons || !sp.Weapons[sp.Weapon] {
+			return fmt.Errorf("invalid weapon %d", sp.Weapon)
+		}
+		if sp.Health > defaultPlayer.maxHealth {
+			return fmt.Errorf("invalid player health %d", sp.Health)
+		}
+		p.camera.pos, p.camera.forward, p.camera.up = sp.Position, sp.Forward, sp.Up
+		p.health = sp.Health
+		p.weapons, p.ammo, p.weapon = sp.Weapons, sp.Ammo, sp.Weapon
+		p.nextShot, p.visorEnd = sp.NextShot, sp.VisorEnd
+		p.triggerHeld = sp.TriggerHeld
+		p.hasShot, p.lastShot = sp.HasShot, sp.LastShot
+		p.hasDamage, p.lastDamage = sp.HasDamage, sp.LastDamage
+		p.lives, p.score = sp.Lives, sp.Score
+	}
+
+	for i, sm := range s.Monsters {
+		m := l.monsters[i]
+		if sm.Frame < 0 || sm.Frame >= len(m.animations) {
+			return fmt.Errorf("invalid monster animation frame %d", sm.Frame)
+		}
+		if sm.State < stateIdle || sm.State > stateDead {
+			return fmt.Errorf("invalid monster state %d", sm.State)
+		}
+		m.transform.translation, m.transform.scale = sm.Position, sm.Scale
+		m.heading = sm.Heading
+		m.patrolGoal = cell{sm.PatrolGoal[0], sm.PatrolGoal[1]}
+		m.state, m.health = sm.State, sm.Health
+		m.animFrame = sm.Frame
+		m.deathTime, m.canAttack, m.canLook = sm.DeathTime, sm.CanAttack, sm.CanLook
+	}
+
+	for i, sd := range s.Doors {
+		d := l.doors[i]
+		d.transform.translation = sd.Position
+		d.isOpening = sd.Opening
+		d.openingStartTime, d.openTime = sd.OpeningStartTime, sd.OpenTime
+		d.closingStartTime, d.closeTime = sd.ClosingStartTime, sd.CloseTime
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	h := newHarness(t, 1)
+	l := h.level()
+
+	monster := h.monsterAt(13, 28)
+	monster.damage(monster.health)
+	l.doors[0].open()
+
+	// medkit at 8,5 heals the player
+	h.player().health = 50
+	h.placePlayer(8, 5, towardsMinusZ)
+	h.run(1)
+	h.placePlayer(10, 5, towardsPlusZ)
+	h.run(100)
+	if l.kills != 1 || l.items != 1 || l.secretsFound != 1 {
+		t.Fatalf("unexpected stats: %d kills, %d items, %d secrets", l.kills, l.items, l.secretsFound)
+	}
+	// save in the middle of a fire cycle, with the face still showing the damage
+	h.player().damage(10)
+	h.script.hold(inputFire, 1)
+	h.runScript()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, quickSaveFile)
+	err := h.g.save(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := newHarness(t, 1)
+	err = h2.g.load(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2 := h2.level()
+
+	p, p2 := h.player(), h2.player()
+	if p2.camera.pos != p.camera.pos || p2.camera.forward != p.camera.forward || p2.health != p.health ||
+		p2.ammo != p.ammo || p2.weapons != p.weapons || p2.weapon != p.weapon {
+		t.Errorf("player was not restored")
+	}
+	if p2.triggerHeld != p.triggerHeld || p2.hasShot != p.hasShot || p2.lastShot != p.lastShot ||
+		p2.hasDamage != p.hasDamage || p2.lastDamage != p.lastDamage || p2.fireFrame() != p.fireFrame() {
+		t.Errorf("fire cycle was not restored")
+	}
+	if l2.kills != l.kills || l2.items != l.items || l2.secretsFound != l.secretsFound || l2.elapsed != l.elapsed {
+		t.Errorf("stats were not restored")
+	}
+	if len(l2.pickups) != len(l.pickups) {
+		t.Errorf("expected %d pickups but got %d", len(l.pickups), len(l2.pickups))
+	}
+	for i, m := range l.monsters {
+		m2 := l2.monsters[i]
+		if m2.state != m.state || m2.health != m.health || m2.transform.translation != m.transform.translation ||
+			m2.frame() != m.frame() {
+			t.Errorf("monster %d was not restored", i)
+		}
+	}
+	for i, d := range l.doors {
+		d2 := l2.doors[i]
+		if d2.isOpening != d.isOpening || d2.transform.translation != d.transform.translation {
+			t.Errorf("door %d was not restored", i)
+		}
+	}
+	if h2.g.clock != h.g.clock {
+		t.Errorf("clock was not restored")
+	}
+	if h2.g.randomSource.draws != h.g.randomSource.draws || h2.g.random.Int63() != h.g.random.Int63() {
+		t.Errorf("random stream was not restored")
+	}
+
+	// both games keep playing the same way
+	h.run(100)
+	h2.run(100)
+	if l2.doors[0].transform.translation != l.doors[0].transform.translation {
+		t.Errorf("door is not moving as in the saved game")
+	}
+}
+
+func TestLoadInvalidSave(t *testing.T) {
+	h := newHarness(t, 1)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for name, s := range map[string]savedGame{
+		"magic":   {Magic: "WOLF", Version: saveVersion},
+		"version": {Magic: saveMagic, Version: saveVersion + 1},
+		"players": {Magic: saveMagic, Version: saveVersion},
+		"map":     {Magic: saveMagic, Version: saveVersion, Level: 1, Map: "level1.map", Players: make([]savedPlayer, 1)},
+	} {
+		fileName := filepath.Join(dir, name+".wsave")
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(fileName, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = h.g.load(fileName)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if h.g.levelNum != 1 || h.g.mapName != "level1.map" || len(h.level().monsters) == 0 {
+			t.Errorf("%s: current level should be kept", name)
+		}
+	}
+}
+
+func TestLoadCorruptSave(t *testing.T) {
+	h := newHarness(t, 1)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.wsave")
+	err := h.g.save(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the level is loaded before the saved state is found to be invalid
+	h.g.setState(gameOver)
+	loads := h.g.levelLoads
+	for name, corrupt := range map[string]func(s *savedGame){
+		"game state":    func(s *savedGame) { s.State = gameVictory + 1 },
+		"monster state": func(s *savedGame) { s.Monsters[0].State = stateDead + 1 },
+		"weapon":        func(s *savedGame) { s.Players[0].Weapon = numWeapons },
+		"not owned":     func(s *savedGame) { s.Players[0].Weapon = gunWeapon },
+		"health":        func(s *savedGame) { s.Players[0].Health = defaultPlayer.maxHealth + 1 },
+	} {
+		var s savedGame
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupt(&s)
+		fileName := filepath.Join(dir, "corrupt.wsave")
+		corrupted, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(fileName, corrupted, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = h.g.load(fileName)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if h.g.levelLoads != loads || h.g.state != gameOver {
+			t.Errorf("%s: game state should be kept", name)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "wolfengo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,271 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// snapshots are sent to clients every snapshotInterval simulation ticks
+	snapshotInterval = 4
+	// max number of input frames queued for a player; when more are received the oldest are dropped
+	maxQueuedInput = 64
+)
+
+// netPacket is a datagram received by the server.
+type netPacket struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// netInput is the input source of a player connected to the server.
+type netInput struct {
+	addr  *net.UDPAddr
+	queue []netInputFrame
+	// sequence number of the last frame received and of the last frame applied
+	receivedSeq, processedSeq uint32
+}
+
+func (ni *netInput) push(frames []netInputFrame) {
+	for _, f := range frames {
+		if f.Seq <= ni.receivedSeq {
+			// already received with a previous message
+			continue
+		}
+		ni.receivedSeq = f.Seq
+		ni.queue = append(ni.queue, f)
+	}
+	if len(ni.queue) > maxQueuedInput {
+		ni.queue = ni.queue[len(ni.queue)-maxQueuedInput:]
+	}
+}
+
+// next returns the oldest frame received; when there is none the player stands still.
+func (ni *netInput) next() (inputFrame, error) {
+	if len(ni.queue) == 0 {
+		return inputFrame{}, nil
+	}
+	f := ni.queue[0]
+	ni.queue = ni.queue[1:]
+	ni.processedSeq = f.Seq
+	return f.frame(), nil
+}
+
+// server runs the authoritative simulation of a game, with one client for each player.
+type server struct {
+	conn    *net.UDPConn
+	game    *Game
+	clients []*netInput
+	packets chan netPacket
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func listenServer(addr string, numPlayers int, deathmatch bool, seed int64, startLevel uint, startMap string) (*server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, netError{addr, err}
+	}
+
+	s := &server{packets: make(chan netPacket, 64), done: make(chan struct{})}
+	sources := make([]inputSource, numPlayers)
+	for i := range sources {
+		ni := &netInput{}
+		s.clients = append(s.clients, ni)
+		sources[i] = ni
+	}
+	s.game, err = NewGame(seed, startLevel, startMap, deathmatch, sources...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, netError{addr, err}
+	}
+
+	s.wg.Add(1)
+	go s.read()
+
+	return s, nil
+}
+
+func (s *server) read() {
+	defer s.wg.Done()
+	defer close(s.packets)
+
+	buf := make([]byte, netMaxPacketSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			// connection closed by stop()
+			return
+		}
+		select {
+		case s.packets <- netPacket{addr, append([]byte(nil), buf[:n]...)}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// stop closes the connection and waits for the server to stop reading.
+func (s *server) stop() error {
+	close(s.done)
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+// run waits for all players to join, then simulates the game in real time until the server is stopped.
+func (s *server) run() error {
+	fmt.Printf("waiting for %d players on %v\n", len(s.clients), s.conn.LocalAddr())
+	for !s.allJoined() {
+		p, ok := <-s.packets
+		if !ok {
+			return nil
+		}
+		s.handle(p)
+	}
+	fmt.Println("all players joined")
+
+	ticker := time.NewTicker(frameTime)
+	defer ticker.Stop()
+
+	var tick uint
+	for {
+		select {
+		case p, ok := <-s.packets:
+			if !ok {
+				return nil
+			}
+			s.handle(p)
+			continue
+		case <-ticker.C:
+		}
+
+		s.game.tick()
+		err := s.game.input()
+		if err != nil {
+			return err
+		}
+		err = s.game.update()
+		if err != nil {
+			return err
+		}
+
+		tick++
+		if tick%snapshotInterval == 0 {
+			s.sendSnapshots()
+		}
+	}
+}
+
+func (s *server) allJoined() bool {
+	for _, c := range s.clients {
+		if c.addr == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// client returns the player slot of the specified address, if any.
+func (s *server) client(addr *net.UDPAddr) (int, *netInput) {
+	for i, c := range s.clients {
+		if c.addr != nil && c.addr.String() == addr.String() {
+			return i, c
+		}
+	}
+	return -1, nil
+}
+
+func (s *server) handle(p netPacket) {
+	r := bytes.NewReader(p.data)
+	t, err := readNetMessage(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, netError{p.addr.String(), err})
+		return
+	}
+
+	switch t {
+	case netMsgJoin:
+		s.join(p.addr)
+	case netMsgInput:
+		_, c := s.client(p.addr)
+		if c == nil {
+			return
+		}
+		frames, err := decodeInput(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, netError{p.addr.String(), err})
+			return
+		}
+		c.push(frames)
+	}
+}
+
+// join assigns the first free player slot to the address; join requests are repeated
+// by clients until they receive the welcome message, thus it is sent again to players
+// which already joined.
+func (s *server) join(addr *net.UDPAddr) {
+	player, _ := s.client(addr)
+	if player == -1 {
+		for i, c := range s.clients {
+			if c.addr == nil {
+				c.addr = addr
+				player = i
+				fmt.Printf("player %d joined from %v\n", i+1, addr)
+				break
+			}
+		}
+	}
+	if player == -1 {
+		s.send(addr, newNetMessage(netMsgFull).Bytes())
+		return
+	}
+
+	msg, err := encodeWelcome(player, s.game)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	s.send(addr, msg)
+}
+
+func (s *server) sendSnapshots() {
+	for _, c := range s.clients {
+		if c.addr != nil {
+			s.send(c.addr, encodeSnapshot(s.game, c.processedSeq))
+		}
+	}
+}
+
+func (s *server) send(addr *net.UDPAddr, msg []byte) {
+	_, err := s.conn.WriteToUDP(msg, addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, netError{addr.String(), err})
+	}
+}
@@ -30,10 +30,12 @@ import (
 type Shader struct {
 	program  uint32
 	uniforms map[string]int32
+	// multiplies the color of all materials, e.g. to brighten the scene
+	tint Vector3f
 }
 
 func NewShader(withUpdateUniforms bool) (*Shader, error) {
-	s := &Shader{}
+	s := &Shader{tint: Vector3f{1, 1, 1}}
 	s.program = gl.CreateProgram()
 	if s.program == 0 {
 		return nil, errors.New("shader creation failed: could not find valid memory location when creating program")
@@ -154,5 +156,5 @@ func (s *Shader) updateUniforms(projectedMatrix Matrix4f, material *Material) {
 	}
 
 	s.setUniformM("transform", projectedMatrix)
-	s.setUniform("color", material.color)
+	s.setUniform("color", material.color.mul(s.tint))
 }
@@ -0,0 +1,58 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+// alert wakes the idle and patrolling monsters that hear a noise made at the specified position,
+// e.g. gunfire: as in the original game sound fills the area connected to it, blocked by walls
+// and closed doors; monsters in ambush ignore it.
+func (l *Level) alert(pos Vector3f) {
+	closed := make(map[cell]bool, len(l.doors))
+	for _, d := range l.doors {
+		if !d.isOpening {
+			closed[cellAt(d.closePosition)] = true
+		}
+	}
+
+	heard := l.paths.area(cellAt(pos), closed)
+	for _, m := range l.monsters {
+		if m.unaware() && !m.ambush && heard[cellAt(m.transform.translation)] {
+			m.state = stateChase
+		}
+	}
+}
+
+// area returns the cells connected to the specified one without passing through walls,
+// diagonals or blocked cells.
+func (pf *pathfinder) area(from cell, blocked map[cell]bool) map[cell]bool {
+	visited := map[cell]bool{from: true}
+	queue := []cell{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, nb := range neighbours[:4] {
+			next := cell{current.x + nb.dx, current.y + nb.dy}
+			if visited[next] || blocked[next] || !pf.walkable(next) {
+				continue
+			}
+			visited[next] = true
+			queue = append(queue, next)
+		}
+	}
+	return visited
+}
@@ -0,0 +1,77 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"testing"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+func TestGunfireAlertsConnectedArea(t *testing.T) {
+	h := newHarness(t, 1)
+	inHall, behindDoor, beyondRoom := h.monsterAt(13, 28), h.monsterAt(17, 18), h.monsterAt(22, 18)
+
+	// facing a wall, so that no monster is hit
+	h.placePlayer(14, 14, towardsMinusZ)
+	h.script.hold(inputFire, 1)
+	h.runScript()
+	if inHall.state != stateChase {
+		t.Error("monster in the same area did not hear the gunfire")
+	}
+	for _, m := range []*Monster{behindDoor, beyondRoom} {
+		if m.state != stateIdle {
+			t.Error("monster behind a closed door heard the gunfire")
+		}
+	}
+
+	// sound passes through open doors
+	h.doorAt(19, 15).open()
+	h.placePlayer(19, 13, towardsMinusZ)
+	// wait for the pistol to be ready again
+	h.runFor(weaponDefs[pistolWeapon].fireInterval)
+	h.script.hold(inputFire, 1)
+	h.runScript()
+	for _, m := range []*Monster{behindDoor, beyondRoom} {
+		if m.state == stateIdle {
+			t.Error("monster behind an open door did not hear the gunfire")
+		}
+	}
+}
+
+func TestAmbushIgnoresGunfire(t *testing.T) {
+	for _, tc := range []struct {
+		actor wolfmap.Actor
+		state int
+	}{
+		{wolfmap.Actor{Facing: wolfmap.East, Patrol: true}, stateChase},
+		{wolfmap.Actor{Facing: wolfmap.East, Ambush: true}, stateIdle},
+	} {
+		h := newHarness(t, 1)
+		m := h.monsterAt(13, 28)
+		m.setActor(tc.actor)
+
+		h.placePlayer(14, 14, towardsMinusZ)
+		h.script.hold(inputFire, 1)
+		h.runScript()
+		if m.state != tc.state {
+			t.Errorf("%+v: expected state %d after gunfire, got %d", tc.actor, tc.state, m.state)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// bonus for each ratio (kills, items, secrets) at 100%
+	perfectRatioBonus = 10000
+	// bonus for each second under par time
+	parTimeBonus = 500
+	// a player standing this close to the center of a secret cell finds it
+	secretDistance = 0.5
+)
+
+// secret is a spot of the map counted when found by a player.
+type secret struct {
+	pos   Vector3f
+	found bool
+}
+
+// levelStats are the statistics of a level, as shown by the tally screen and written to the stats log.
+type levelStats struct {
+	Level        uint   `json:"level"`
+	Map          string `json:"map"`
+	Title        string `json:"title,omitempty"`
+	Kills        int    `json:"kills"`
+	TotalKills   int    `json:"totalKills"`
+	Items        int    `json:"items"`
+	TotalItems   int    `json:"totalItems"`
+	Secrets      int    `json:"secrets"`
+	TotalSecrets int    `json:"totalSecrets"`
+	// in seconds; par time is 0 when the map has none
+	Time    float64 `json:"time"`
+	ParTime float64 `json:"parTime"`
+	Bonus   int     `json:"bonus"`
+	// sum of the scores of all players, bonus included
+	Score int `json:"score"`
+}
+
+func (l *Level) findSecrets() {
+	for _, s := range l.secrets {
+		if s.found {
+			continue
+		}
+		if l.nearestPlayer(s.pos, secretDistance) != nil {
+			s.found = true
+			l.secretsFound++
+		}
+	}
+}
+
+func (l *Level) stats() levelStats {
+	s := levelStats{
+		Level:        l.game.levelNum,
+		Map:          l.game.mapName,
+		Title:        l.level.Title,
+		Kills:        l.kills,
+		TotalKills:   l.totalKills,
+		Items:        l.items,
+		TotalItems:   l.totalItems,
+		Secrets:      l.secretsFound,
+		TotalSecrets: len(l.secrets),
+		Time:         l.elapsed.Seconds(),
+		ParTime:      l.level.ParTime.Seconds(),
+	}
+	for _, p := range l.players {
+		s.Score += p.score
+	}
+	return s
+}
+
+// bonus returns the points given to each player for completing the level.
+func (s *levelStats) bonus() int {
+	var bonus int
+	for _, r := range [][2]int{{s.Kills, s.TotalKills}, {s.Items, s.TotalItems}, {s.Secrets, s.TotalSecrets}} {
+		if r[1] > 0 && r[0] == r[1] {
+			bonus += perfectRatioBonus
+		}
+	}
+	if s.ParTime > s.Time {
+		bonus += int(s.ParTime-s.Time) * parTimeBonus
+	}
+	return bonus
+}
+
+// tally gives the bonus of the completed level to players and logs its statistics, if requested;
+// the tally itself is shown by the HUD.
+func (g *Game) tally() error {
+	if g.deathmatch {
+		return nil
+	}
+
+	s := g.level.stats()
+	s.Bonus = s.bonus()
+	for _, p := range g.level.players {
+		p.score += s.Bonus
+		s.Score += s.Bonus
+	}
+	g.level.tally = &s
+
+	if g.statsLog != nil {
+		err := json.NewEncoder(g.statsLog).Encode(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ratio(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", n*100/total)
+}
+
+func formatTime(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%02d:%02d", int(d/time.Minute), int(d%time.Minute/time.Second))
+}
+
+// tallyLines returns the lines of the tally screen.
+func (s *levelStats) tallyLines() []string {
+	par := "--:--"
+	if s.ParTime > 0 {
+		par = formatTime(s.ParTime)
+	}
+	return []string{
+		fmt.Sprintf("kills   %4s", ratio(s.Kills, s.TotalKills)),
+		fmt.Sprintf("items   %4s", ratio(s.Items, s.TotalItems)),
+		fmt.Sprintf("secrets %4s", ratio(s.Secrets, s.TotalSecrets)),
+		fmt.Sprintf("time %s par %s", formatTime(s.Time), par),
+		fmt.Sprintf("bonus %d", s.Bonus),
+	}
+}
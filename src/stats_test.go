@@ -0,0 +1,117 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// statsBuffer collects the stats log in memory.
+type statsBuffer struct {
+	bytes.Buffer
+}
+
+func (sb *statsBuffer) Close() error {
+	return nil
+}
+
+func TestLevelStats(t *testing.T) {
+	h := newHarness(t, 1)
+	var log statsBuffer
+	h.g.statsLog = &log
+
+	l := h.level()
+	if l.totalKills != 6 || l.totalItems != 4 || len(l.secrets) != 1 {
+		t.Fatalf("unexpected totals: %d kills, %d items, %d secrets", l.totalKills, l.totalItems, len(l.secrets))
+	}
+
+	monster := h.monsterAt(13, 28)
+	h.placePlayerAt(13.6, 24.5, towardsPlusZ)
+	for i := 0; i < 375 && monster.alive(); i++ {
+		h.script.hold(inputFire, 1).idle(1)
+		h.runScript()
+	}
+	h.removeMonsters()
+
+	h.placePlayer(10, 5, towardsPlusZ)
+	h.run(1)
+	if l.secretsFound != 1 {
+		t.Errorf("secret was not found")
+	}
+
+	// medkit at 8,5 heals the player
+	h.player().health = 50
+	h.placePlayer(8, 5, towardsMinusZ)
+	h.run(1)
+
+	h.placePlayer(5, 26, towardsPlusZ)
+	h.script.hold(inputUse, 1)
+	h.runScript()
+	if h.g.state != gameLevelComplete || l.tally == nil {
+		t.Fatal("level should be complete")
+	}
+
+	var s levelStats
+	err := json.Unmarshal(log.Bytes(), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != 1 || s.Map != "level1.map" || s.Kills != 1 || s.TotalKills != 6 || s.Items != 1 || s.TotalItems != 4 ||
+		s.Secrets != 1 || s.TotalSecrets != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+	if s.Time <= 0 || s.Time != l.elapsed.Seconds() {
+		t.Errorf("unexpected time %v", s.Time)
+	}
+	if s.Bonus != perfectRatioBonus || s.Score != killScore+perfectRatioBonus || h.player().score != s.Score {
+		t.Errorf("unexpected bonus %d and score %d", s.Bonus, s.Score)
+	}
+
+	// time stops while the tally is shown
+	h.runFor(time.Second)
+	if l.elapsed.Seconds() != s.Time {
+		t.Error("level time should stop once the level is complete")
+	}
+}
+
+func TestTallyBonus(t *testing.T) {
+	for _, tc := range []struct {
+		stats levelStats
+		bonus int
+	}{
+		{levelStats{Kills: 3, TotalKills: 4, Time: 60}, 0},
+		{levelStats{Kills: 4, TotalKills: 4, Items: 2, TotalItems: 2, Time: 60}, 2 * perfectRatioBonus},
+		// no secrets to find
+		{levelStats{Kills: 0, TotalKills: 1, Time: 60}, 0},
+		{levelStats{Time: 80.5, ParTime: 90}, 9 * parTimeBonus},
+		{levelStats{Time: 100, ParTime: 90}, 0},
+	} {
+		if bonus := tc.stats.bonus(); bonus != tc.bonus {
+			t.Errorf("%+v: expected bonus %d but got %d", tc.stats, tc.bonus, bonus)
+		}
+	}
+
+	lines := (&levelStats{Kills: 1, TotalKills: 3, Time: 95, ParTime: 90}).tallyLines()
+	if lines[0] != "kills    33%" || lines[2] != "secrets    -" || lines[3] != "time 01:35 par 01:30" {
+		t.Errorf("unexpected tally %q", lines)
+	}
+}
@@ -43,6 +43,10 @@ func (te textureError) Error() string {
 
 func NewTexture(fileName string) (*Texture, error) {
 	t := &Texture{}
+	if headless {
+		// null texture, never bound
+		return t, nil
+	}
 	var err error
 	t.ID, err = loadTexture(fileName)
 	if err != nil {
@@ -51,33 +55,72 @@ func NewTexture(fileName string) (*Texture, error) {
 	return t, nil
 }
 
-func loadTexture(fileName string) (uint32, error) {
-	imgFile, err := os.Open("./res/textures/" + fileName)
-	if err != nil {
-		return 0, err
+// NewTextureFromImage creates a texture from an image generated at runtime.
+func NewTextureFromImage(img image.Image) *Texture {
+	t := &Texture{}
+	if headless {
+		// null texture, never bound
+		return t
 	}
+	t.ID = uploadTexture(img)
+	return t
+}
 
-	imgCfg, _, err := image.DecodeConfig(imgFile)
-	if err != nil {
-		return 0, err
+// missingTexture is shown in place of the sprites without art, see NewSpriteTexture.
+var missingTexture *Texture
+
+// NewSpriteTexture loads a sprite; when its file is missing a checkerboard is shown instead,
+// so that the missing art is noticed, and it is reported.
+func NewSpriteTexture(fileName string) (*Texture, error) {
+	if textureExists(fileName) {
+		return NewTexture(fileName)
+	}
+	fmt.Printf("no art for sprite %s, a placeholder is shown\n", fileName)
+	if missingTexture == nil {
+		img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				if (x+y)%2 == 0 {
+					img.SetNRGBA(x, y, color.NRGBA{255, 0, 255, 255})
+				} else {
+					img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+				}
+			}
+		}
+		missingTexture = NewTextureFromImage(img)
 	}
-	_, err = imgFile.Seek(0, 0)
+	return missingTexture, nil
+}
+
+// textureExists returns true when the texture file is among the game resources.
+func textureExists(fileName string) bool {
+	_, err := os.Stat("./res/textures/" + fileName)
+	return err == nil
+}
+
+func loadTexture(fileName string) (uint32, error) {
+	imgFile, err := os.Open("./res/textures/" + fileName)
 	if err != nil {
 		return 0, err
 	}
 
-	w, h := int32(imgCfg.Width), int32(imgCfg.Height)
-
 	img, _, err := image.Decode(imgFile)
 	if err != nil {
 		return 0, err
 	}
 
+	return uploadTexture(img), nil
+}
+
+func uploadTexture(img image.Image) uint32 {
+	bounds := img.Bounds()
+	w, h := int32(bounds.Dx()), int32(bounds.Dy())
+
 	buffer := make([]byte, w*h*4)
 	index := 0
-	for y := 0; y < int(h); y++ {
-		for x := 0; x < int(w); x++ {
-			pixel := img.At(x, y).(color.NRGBA)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
 			buffer[index] = pixel.R
 			buffer[index+1] = pixel.G
 			buffer[index+2] = pixel.B
@@ -107,7 +150,7 @@ func loadTexture(fileName string) (uint32, error) {
 		gl.UNSIGNED_BYTE,
 		gl.Ptr(buffer))
 
-	return texture, nil
+	return texture
 }
 
 func (t *Texture) bind() {
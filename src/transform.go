@@ -57,3 +57,12 @@ func (t *Transform) getProjectedTransformation(c *Camera) Matrix4f {
 
 	return projectionMatrix.mul(cameraRotation.mul(cameraTranslation.mul(transformationMatrix)))
 }
+
+// getOrthoTransformation projects the transformation on a 2D overlay of the specified size,
+// with the origin in the bottom left corner.
+func (t *Transform) getOrthoTransformation(width, height float32) Matrix4f {
+	var projectionMatrix Matrix4f
+	projectionMatrix.initOrthographic(0, width, 0, height, -1, 1)
+
+	return projectionMatrix.mul(t.getTransformation())
+}
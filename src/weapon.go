@@ -0,0 +1,133 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+type weaponType int
+
+const (
+	pistolWeapon weaponType = iota
+	gunWeapon
+	chaingunWeapon
+	rocketWeapon
+	plasmaWeapon
+	numWeapons
+)
+
+type ammoType int
+
+const (
+	pistolAmmo ammoType = iota
+	gunAmmo
+	rocketAmmo
+	plasmaAmmo
+	numAmmoTypes
+)
+
+type weaponDef struct {
+	name    string
+	special wolfmap.Special
+	ammo    ammoType
+	// ammo given when the weapon is picked up
+	pickupAmmo           int
+	damageMin, damageMax int
+	fireInterval         time.Duration
+	// automatic weapons keep firing while the trigger is held, the others fire once per press
+	automatic bool
+	// maximum deviation of each shot from the aim direction, in degrees
+	spread float32
+
+	viewTexture string
+	material    *Material
+	// sprite of the weapon lying on the floor
+	pickupTexture string
+	// view sprites shown in sequence after each shot, before the idle one
+	fireFrames    []weaponFrame
+	fireMaterials []*Material
+}
+
+// weaponFrame is a view sprite of the firing animation, shown for duration.
+type weaponFrame struct {
+	texture  string
+	duration time.Duration
+}
+
+type ammoDef struct {
+	special       wolfmap.Special
+	max           int
+	pickupAmount  int
+	pickupTexture string
+}
+
+// weapons are selected with the number keys, in this order; firing shows the muzzle flash,
+// then the weapon is lowered back to the idle sprite while recovering
+var weaponDefs = [numWeapons]weaponDef{
+	pistolWeapon:   {name: "pistol", special: wolfmap.Pistol, ammo: pistolAmmo, pickupAmmo: 8, damageMin: 20, damageMax: 60, fireInterval: 250 * time.Millisecond, spread: 1, viewTexture: "PISGB0.png", pickupTexture: "PISTA0.png", fireFrames: []weaponFrame{{"PISFA0.png", 80 * time.Millisecond}, {"PISGB0.png", 120 * time.Millisecond}}},
+	gunWeapon:      {name: "gun", special: wolfmap.Gun, ammo: gunAmmo, pickupAmmo: 8, damageMin: 30, damageMax: 70, fireInterval: 400 * time.Millisecond, spread: 4, viewTexture: "SHTGA0.png", pickupTexture: "SHOTA0.png", fireFrames: []weaponFrame{{"SHTFA0.png", 100 * time.Millisecond}, {"SHTGA0.png", 200 * time.Millisecond}}},
+	chaingunWeapon: {name: "chaingun", special: wolfmap.Chaingun, ammo: gunAmmo, pickupAmmo: 20, damageMin: 15, damageMax: 35, fireInterval: 100 * time.Millisecond, automatic: true, spread: 3, viewTexture: "CHGGA0.png", pickupTexture: "MGUNA0.png", fireFrames: []weaponFrame{{"CHGFA0.png", 50 * time.Millisecond}, {"CHGGA0.png", 50 * time.Millisecond}}},
+	rocketWeapon:   {name: "rocket launcher", special: wolfmap.Rocket, ammo: rocketAmmo, pickupAmmo: 5, damageMin: 80, damageMax: 120, fireInterval: 800 * time.Millisecond, viewTexture: "MISGA0.png", pickupTexture: "LAUNA0.png", fireFrames: []weaponFrame{{"MISFA0.png", 150 * time.Millisecond}, {"MISGA0.png", 250 * time.Millisecond}}},
+	plasmaWeapon:   {name: "plasma gun", special: wolfmap.Plasma, ammo: plasmaAmmo, pickupAmmo: 40, damageMin: 25, damageMax: 45, fireInterval: 120 * time.Millisecond, automatic: true, spread: 1, viewTexture: "PLSGA0.png", pickupTexture: "PLASA0.png", fireFrames: []weaponFrame{{"PLSFA0.png", 40 * time.Millisecond}, {"PLSGA0.png", 60 * time.Millisecond}}},
+}
+
+var ammoDefs = [numAmmoTypes]ammoDef{
+	pistolAmmo: {special: wolfmap.PistolAmmo, max: 99, pickupAmount: 8, pickupTexture: "CLIPA0.png"},
+	gunAmmo:    {special: wolfmap.GunAmmo, max: 99, pickupAmount: 10, pickupTexture: "SHELA0.png"},
+	rocketAmmo: {special: wolfmap.RocketAmmo, max: 50, pickupAmount: 5, pickupTexture: "ROCKA0.png"},
+	plasmaAmmo: {special: wolfmap.PlasmaAmmo, max: 200, pickupAmount: 40, pickupTexture: "CELLA0.png"},
+}
+
+func initWeapons() error {
+	// the frames of each weapon share the same textures
+	materials := map[string]*Material{}
+	getMaterial := func(fileName string) (*Material, error) {
+		if m, ok := materials[fileName]; ok {
+			return m, nil
+		}
+		t, err := NewSpriteTexture(fileName)
+		if err != nil {
+			return nil, err
+		}
+		materials[fileName] = NewMaterial(t)
+		return materials[fileName], nil
+	}
+
+	for i := range weaponDefs {
+		def := &weaponDefs[i]
+		var err error
+		def.material, err = getMaterial(def.viewTexture)
+		if err != nil {
+			return err
+		}
+
+		def.fireMaterials = make([]*Material, len(def.fireFrames))
+		for j, f := range def.fireFrames {
+			def.fireMaterials[j], err = getMaterial(f.texture)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdm85/wolfengo/src/wolfmap"
+)
+
+func TestWeaponPickupAndSwitch(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+
+	h.placePlayer(9, 24, towardsPlusZ)
+	h.addPickup(9, 24, wolfmap.Chaingun)
+	h.run(1)
+
+	p := h.player()
+	if len(h.level().pickups) != 0 {
+		t.Fatal("weapon was not picked up")
+	}
+	if !p.weapons[chaingunWeapon] || p.weapon != chaingunWeapon {
+		t.Errorf("player should be holding the chaingun but has weapon %d", p.weapon)
+	}
+	if p.ammo[gunAmmo] != weaponDefs[chaingunWeapon].pickupAmmo {
+		t.Errorf("expected %d gun ammo but got %d", weaponDefs[chaingunWeapon].pickupAmmo, p.ammo[gunAmmo])
+	}
+
+	// weapons not owned cannot be selected
+	h.script.selectWeapon(5)
+	h.runScript()
+	if p.weapon != chaingunWeapon {
+		t.Errorf("selected a weapon not owned")
+	}
+	h.script.selectWeapon(1)
+	h.runScript()
+	if p.weapon != pistolWeapon {
+		t.Errorf("pistol was not selected")
+	}
+}
+
+func TestAmmoPickupLimit(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+
+	p := h.player()
+	p.ammo[pistolAmmo] = ammoDefs[pistolAmmo].max
+	h.placePlayer(9, 24, towardsPlusZ)
+	h.addPickup(9, 24, wolfmap.PistolAmmo)
+	h.run(1)
+	if len(h.level().pickups) != 1 {
+		t.Fatal("ammo was picked up at maximum")
+	}
+
+	p.ammo[pistolAmmo] = ammoDefs[pistolAmmo].max - 1
+	h.run(1)
+	if len(h.level().pickups) != 0 || p.ammo[pistolAmmo] != ammoDefs[pistolAmmo].max {
+		t.Errorf("expected ammo to be picked up up to %d, got %d", ammoDefs[pistolAmmo].max, p.ammo[pistolAmmo])
+	}
+}
+
+func TestFiringUsesAmmo(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+	h.placePlayer(9, 24, towardsPlusZ)
+
+	p := h.player()
+	start := p.ammo[pistolAmmo]
+	h.script.hold(inputFire, 1)
+	h.runScript()
+	if p.ammo[pistolAmmo] != start-1 {
+		t.Fatalf("expected %d ammo after one shot but got %d", start-1, p.ammo[pistolAmmo])
+	}
+
+	// the pistol is semi-automatic: holding the trigger does not fire again
+	h.script.hold(inputFire, int(time.Second/frameTime))
+	h.runScript()
+	if p.ammo[pistolAmmo] != start-1 {
+		t.Errorf("fired again without releasing the trigger")
+	}
+
+	// fire rate limits the shots when pressing the trigger repeatedly
+	h.script.idle(1)
+	for i := 0; i < 10; i++ {
+		h.script.hold(inputFire, 1).idle(1)
+	}
+	h.runScript()
+	if p.ammo[pistolAmmo] != start-2 {
+		t.Errorf("fired before the weapon was ready")
+	}
+
+	for i := 0; i < int(10*time.Second/frameTime)/2; i++ {
+		h.script.hold(inputFire, 1).idle(1)
+	}
+	h.runScript()
+	if p.ammo[pistolAmmo] != 0 {
+		t.Errorf("expected all ammo to be used but %d left", p.ammo[pistolAmmo])
+	}
+}
+
+func TestAutomaticFire(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+	h.placePlayer(9, 24, towardsPlusZ)
+
+	p := h.player()
+	p.giveWeapon(chaingunWeapon)
+	start := p.ammo[gunAmmo]
+
+	h.script.hold(inputFire, int(time.Second/frameTime))
+	h.runScript()
+	expected := int(time.Second / weaponDefs[chaingunWeapon].fireInterval)
+	if shots := start - p.ammo[gunAmmo]; shots != expected {
+		t.Errorf("expected %d shots in a second but got %d", expected, shots)
+	}
+}
+
+func TestFiringAnimation(t *testing.T) {
+	h := newHarness(t, 1)
+	h.removeMonsters()
+	h.removePickups()
+	h.placePlayer(9, 24, towardsPlusZ)
+
+	p := h.player()
+	def := &weaponDefs[pistolWeapon]
+	if p.viewMaterial() != def.material {
+		t.Fatal("expected idle sprite before firing")
+	}
+
+	if len(def.fireFrames) < 2 || def.fireMaterials[0] == def.material {
+		t.Fatal("expected a muzzle flash followed by other frames")
+	}
+
+	h.script.hold(inputFire, 1)
+	h.runScript()
+	for i, f := range def.fireFrames {
+		if p.fireFrame() != i || p.viewMaterial() != def.fireMaterials[i] {
+			t.Errorf("expected firing frame %d, got %d", i, p.fireFrame())
+		}
+		// still in the same frame until its duration has passed
+		h.runFor(f.duration - frameTime)
+		if p.fireFrame() != i {
+			t.Errorf("firing frame %d is shown for less than %v", i, f.duration)
+		}
+		h.run(1)
+	}
+	if p.fireFrame() != -1 || p.viewMaterial() != def.material {
+		t.Error("expected idle sprite after the firing animation")
+	}
+}
+
+func TestWeaponSprites(t *testing.T) {
+	newHarness(t, 1)
+
+	// each weapon and ammo type has its own sprites, even if there is no art for them yet
+	textures := map[string]string{}
+	add := func(fileName, what string) {
+		if other, ok := textures[fileName]; ok {
+			t.Errorf("%s uses the same sprite %s as %s", what, fileName, other)
+		}
+		textures[fileName] = what
+	}
+	for _, def := range weaponDefs {
+		add(def.viewTexture, def.name)
+		add(def.pickupTexture, def.name+" pickup")
+		if pickupDefs[def.special].texture != def.pickupTexture {
+			t.Errorf("%s: unexpected pickup sprite %s", def.name, pickupDefs[def.special].texture)
+		}
+	}
+	for _, def := range ammoDefs {
+		add(def.pickupTexture, string(def.special)+" ammo")
+	}
+
+	// missing art is replaced by a placeholder
+	if weaponDefs[pistolWeapon].material.texture == missingTexture {
+		t.Error("pistol sprite should be loaded")
+	}
+	if textureExists(weaponDefs[gunWeapon].viewTexture) || weaponDefs[gunWeapon].material.texture != missingTexture {
+		t.Error("gun sprite should be a placeholder")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+// Direction is one of the eight compass directions of the map, north being towards the first row.
+type Direction int
+
+const (
+	NoDirection Direction = iota
+	North
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+// deltas are the offsets to the next cell in each direction, as rows (x) and columns (y).
+var deltas = [...][2]int{
+	North:     {-1, 0},
+	NorthEast: {-1, 1},
+	East:      {0, 1},
+	SouthEast: {1, 1},
+	South:     {1, 0},
+	SouthWest: {1, -1},
+	West:      {0, -1},
+	NorthWest: {-1, -1},
+}
+
+// Delta returns the offset to the next cell in the direction, as rows (x) and columns (y).
+func (d Direction) Delta() (dx, dy int) {
+	if d <= NoDirection || d > NorthWest {
+		return 0, 0
+	}
+	return deltas[d][0], deltas[d][1]
+}
+
+// Actor is the annotation of a cell in the optional ACTORS block: monsters placed
+// in the cell start facing a direction, possibly patrolling or in ambush, while
+// empty cells can hold turn markers for the patrol routes.
+type Actor struct {
+	// direction faced by the monster of the cell
+	Facing Direction
+	// the monster walks in the direction faced until it is alerted
+	Patrol bool
+	// the monster stands still and does not react to sounds
+	Ambush bool
+	// direction taken by the patrolling monsters reaching the cell
+	Turn Direction
+}
+
+// actorChars are the characters of the ACTORS block; turn markers use the numeric keypad layout.
+var actorChars = map[byte]Actor{
+	'n': {Facing: North}, 'e': {Facing: East}, 's': {Facing: South}, 'w': {Facing: West},
+	'N': {Facing: North, Patrol: true}, 'E': {Facing: East, Patrol: true},
+	'S': {Facing: South, Patrol: true}, 'W': {Facing: West, Patrol: true},
+	'^': {Facing: North, Ambush: true}, '>': {Facing: East, Ambush: true},
+	'v': {Facing: South, Ambush: true}, '<': {Facing: West, Ambush: true},
+	'8': {Turn: North}, '9': {Turn: NorthEast}, '6': {Turn: East}, '3': {Turn: SouthEast},
+	'2': {Turn: South}, '1': {Turn: SouthWest}, '4': {Turn: West}, '7': {Turn: NorthWest},
+}
+
+// char returns the ACTORS block character of the actor, if any.
+func (a Actor) char() (byte, bool) {
+	for c, ca := range actorChars {
+		if ca == a {
+			return c, true
+		}
+	}
+	return ' ', a == Actor{}
+}
+
+// Actor returns the annotation at x,y; cells out of the map, maps without ACTORS
+// block and invalid characters have none.
+func (m *Map) Actor(x, y int) Actor {
+	if m.Actors == nil || !m.InBounds(x, y) {
+		return Actor{}
+	}
+	return actorChars[m.Actors[x][y]]
+}
@@ -0,0 +1,94 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadActors(t *testing.T) {
+	data := testMap([]string{
+		"      ",
+		" 1111 ",
+		" 1111 ",
+		"      ",
+		"      ",
+		"      ",
+	}, []string{
+		"      ",
+		" AeD  ",
+		"  eoX ",
+		"      ",
+		"      ",
+		"      ",
+	}) + "ACTORS:\n" + strings.Join([]string{
+		"      ",
+		"  W 9 ",
+		"  ^s  ",
+		"      ",
+		"      ",
+		"      ",
+	}, "\n") + "\n"
+	m, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		x, y  int
+		actor Actor
+	}{
+		{1, 2, Actor{Facing: West, Patrol: true}},
+		{1, 3, Actor{}},
+		{1, 4, Actor{Turn: NorthEast}},
+		{2, 2, Actor{Facing: North, Ambush: true}},
+		{2, 3, Actor{Facing: South}},
+		{-1, 0, Actor{}},
+	} {
+		if a := m.Actor(tc.x, tc.y); a != tc.actor {
+			t.Errorf("cell %d,%d: expected %+v but got %+v", tc.x, tc.y, tc.actor, a)
+		}
+	}
+	if problems := Check(m); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+	if dx, dy := NorthEast.Delta(); dx != -1 || dy != 1 {
+		t.Errorf("unexpected north-east offset %d,%d", dx, dy)
+	}
+
+	// actors must be placed on monsters and turn markers in the walkable area
+	m.Actors[1][1] = 'e'
+	m.Actors[3][1] = '6'
+	m.Actors[1][5] = 'x'
+	problems := Check(m)
+	if len(problems) != 3 || problems[0].Column != 2 || problems[1].Column != 6 || problems[2].Column != 2 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	// maps without ACTORS block have no annotations
+	m, err = Read(strings.NewReader(testMap([]string{"1"}, []string{"A"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Actors != nil || m.Actor(0, 0) != (Actor{}) {
+		t.Error("unexpected actors")
+	}
+}
@@ -0,0 +1,233 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Problem is a semantic issue found in a map.
+type Problem struct {
+	Line, Column int
+	Msg          string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Msg)
+}
+
+// pos returns the line and column of the cell x,y in block b.
+func (m *Map) pos(b block, x, y int) (int, int) {
+	return m.blockLines[b] + x, y + 1
+}
+
+type checker struct {
+	m        *Map
+	problems []Problem
+}
+
+func (c *checker) addf(b block, x, y int, format string, a ...interface{}) {
+	line, col := c.m.pos(b, x, y)
+	c.problems = append(c.problems, Problem{line, col, fmt.Sprintf(format, a...)})
+}
+
+// knownSpecials are the specials which can be placed in the SPECIALS block.
+var knownSpecials = map[Special]bool{
+	PlayerA:        true,
+	DoorSpecial:    true,
+	MonsterSpecial: true,
+	DogSpecial:     true,
+	OfficerSpecial: true,
+	BossSpecial:    true,
+	SmallMedkit:    true,
+	BigMedkit:      true,
+	ExitSpecial:    true,
+	SecretSpecial:  true,
+	Pistol:         true,
+	Gun:            true,
+	Chaingun:       true,
+	Rocket:         true,
+	Plasma:         true,
+	PistolAmmo:     true,
+	GunAmmo:        true,
+	RocketAmmo:     true,
+	PlasmaAmmo:     true,
+
+	LightAmplificatorVisor: true,
+}
+
+// Check returns all semantic problems of the map, sorted by position.
+func Check(m *Map) []Problem {
+	c := checker{m: m}
+
+	var starts, secondStarts [][2]int
+	for x := 0; x < m.Height; x++ {
+		for y := 0; y < m.Width; y++ {
+			if m.IsEmpty(x, y) {
+				continue
+			}
+
+			special := m.Special(x, y)
+			if m.Walls[x][y] == ' ' && m.Planes[x][y] == ' ' {
+				c.addf(specialsBlock, x, y, "special '%c' outside walkable area", special)
+				continue
+			}
+
+			c.checkWallIndex(wallsBlock, x, y, m.Walls[x][y])
+			c.checkWallIndex(planesBlock, x, y, m.Planes[x][y])
+
+			if m.isEdge(x, y) {
+				c.addf(wallsBlock, x, y, "walkable cell on map edge")
+			}
+
+			switch special {
+			case Empty:
+			case PlayerA:
+				starts = append(starts, [2]int{x, y})
+			case PlayerB:
+				secondStarts = append(secondStarts, [2]int{x, y})
+			case DoorSpecial:
+				if !m.isValidDoor(x, y) {
+					c.addf(specialsBlock, x, y, "door is not between two walls")
+				}
+			default:
+				if !knownSpecials[special] {
+					c.addf(specialsBlock, x, y, "unrecognized special '%c'", special)
+				}
+			}
+		}
+	}
+
+	if len(starts) == 0 {
+		c.problems = append(c.problems, Problem{m.blockLines[specialsBlock], 1, fmt.Sprintf("missing player start '%c'", PlayerA)})
+	} else {
+		for _, s := range starts[1:] {
+			c.addf(specialsBlock, s[0], s[1], "duplicate player start '%c'", PlayerA)
+		}
+		c.checkExits(starts[0][0], starts[0][1])
+	}
+	c.checkActors()
+
+	// the second player start is optional
+	for i := 1; i < len(secondStarts); i++ {
+		c.addf(specialsBlock, secondStarts[i][0], secondStarts[i][1], "duplicate player start '%c'", PlayerB)
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		a, b := c.problems[i], c.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return c.problems
+}
+
+func (c *checker) checkWallIndex(b block, x, y int, v byte) {
+	if v == ' ' {
+		c.addf(b, x, y, "missing %s value for walkable cell", blockNames[b])
+		return
+	}
+	i, ok := c.m.TileIndex(v)
+	if !ok {
+		c.addf(b, x, y, "invalid tile character '%c'", v)
+		return
+	}
+	if _, ok := c.m.WallDefs[i]; !ok {
+		c.addf(b, x, y, "undefined wall index '%c' (wall%d)", v, i)
+	}
+}
+
+// checkActors reports the annotations of the ACTORS block which cannot be applied.
+func (c *checker) checkActors() {
+	m := c.m
+	if m.Actors == nil {
+		return
+	}
+	for x := 0; x < m.Height; x++ {
+		for y := 0; y < m.Width; y++ {
+			v := m.Actors[x][y]
+			if v == ' ' {
+				continue
+			}
+			a, ok := actorChars[v]
+			switch {
+			case !ok:
+				c.addf(actorsBlock, x, y, "invalid actor character '%c'", v)
+			case a.Facing != NoDirection && !m.Special(x, y).IsMonster():
+				c.addf(actorsBlock, x, y, "actor '%c' without monster", v)
+			case a.Turn != NoDirection && m.IsEmpty(x, y):
+				c.addf(actorsBlock, x, y, "turn marker '%c' outside walkable area", v)
+			}
+		}
+	}
+}
+
+// isValidDoor returns true when the door at x,y has walls on exactly two opposite sides.
+func (m *Map) isValidDoor(x, y int) bool {
+	xDoor := m.IsEmpty(x, y-1) && m.IsEmpty(x, y+1)
+	yDoor := m.IsEmpty(x-1, y) && m.IsEmpty(x+1, y)
+
+	return xDoor != yDoor
+}
+
+// checkExits reports the exits which cannot be reached from the start position; doors are considered passable.
+func (c *checker) checkExits(startX, startY int) {
+	m := c.m
+	visited := make([][]bool, m.Height)
+	for x := range visited {
+		visited[x] = make([]bool, m.Width)
+	}
+
+	queue := [][2]int{{startX, startY}}
+	visited[startX][startY] = true
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+
+		for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			x, y := cell[0]+d[0], cell[1]+d[1]
+			if m.IsEmpty(x, y) || visited[x][y] {
+				continue
+			}
+			visited[x][y] = true
+			queue = append(queue, [2]int{x, y})
+		}
+	}
+
+	var exits int
+	for x := 0; x < m.Height; x++ {
+		for y := 0; y < m.Width; y++ {
+			if m.Special(x, y) != ExitSpecial {
+				continue
+			}
+			exits++
+			if !visited[x][y] {
+				c.addf(specialsBlock, x, y, "exit is not reachable from player start")
+			}
+		}
+	}
+
+	if exits == 0 {
+		c.problems = append(c.problems, Problem{m.blockLines[specialsBlock], 1, fmt.Sprintf("no exit '%c'", ExitSpecial)})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"strings"
+	"testing"
+)
+
+// testMap builds a map with a single wall definition and the same MAP and PLANES blocks.
+func testMap(walls, specials []string) string {
+	var sb strings.Builder
+	sb.WriteString("wall1\t{1.00,0.75,0.75,1.00}\n")
+	sb.WriteString("lengthmap       00" + string(rune('0'+len(walls))) + "\n")
+	sb.WriteString("MAP:\n" + strings.Join(walls, "\n") + "\n")
+	sb.WriteString("PLANES:\n" + strings.Join(walls, "\n") + "\n")
+	sb.WriteString("SPECIALS:\n" + strings.Join(specials, "\n") + "\n")
+	return sb.String()
+}
+
+func TestReadSyntaxErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		line int
+	}{
+		{"wall1 {1.00,0.75}\n", 1},
+		{"wall1 {1.00,0.75,0.75,1.00}\nMAP:\n", 2},
+		{"lengthmap 002\nMAP:\n11\n1\n", 4},
+		{"lengthmap 002\nMAP:\n11\n111\n", 4},
+		{"lengthmap 002\nMAP:\n11\n11\nSPECIALS:\n", 5},
+	} {
+		_, err := Read(strings.NewReader(tc.data))
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected a syntax error but got %v", tc.data, err)
+			continue
+		}
+		if se.Line != tc.line {
+			t.Errorf("%q: expected error at line %d but got %v", tc.data, tc.line, se)
+		}
+	}
+}
+
+func TestCheckValidMap(t *testing.T) {
+	m, err := Read(strings.NewReader(testMap([]string{
+		"     ",
+		"     ",
+		" 111 ",
+		"     ",
+		"     ",
+	}, []string{
+		"     ",
+		"     ",
+		" AdX ",
+		"     ",
+		"     ",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if problems := Check(m); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestCheckProblems(t *testing.T) {
+	m, err := Read(strings.NewReader(testMap([]string{
+		"1      ",
+		" 11  2 ",
+		" 11    ",
+		" 1     ",
+		" 1  1  ",
+		"       ",
+		"       ",
+	}, []string{
+		"       ",
+		" AB    ",
+		" dA    ",
+		" Z     ",
+		" B  X  ",
+		"  e    ",
+		"       ",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"4:1: walkable cell on map edge",
+		"5:6: undefined wall index '2' (wall2)",
+		"13:6: undefined wall index '2' (wall2)",
+		"22:2: door is not between two walls",
+		"22:3: duplicate player start 'A'",
+		"23:2: unrecognized special 'Z'",
+		"24:2: duplicate player start 'B'",
+		"24:5: exit is not reachable from player start",
+		"25:3: special 'e' outside walkable area",
+	}
+
+	problems := Check(m)
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems but got %d: %v", len(expected), len(problems), problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Errorf("expected %q but got %q", expected[i], p.String())
+		}
+	}
+}
@@ -0,0 +1,414 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Wolfenstein 3D stores levels in a GAMEMAPS file, indexed by a MAPHEAD file;
+// each level has three planes (walls, objects and a third unused one),
+// compressed with Carmack compression on top of RLEW compression.
+const (
+	maxGameMapsLevels = 100
+	numPlanes         = 3
+	carmackNearTag    = 0xa7
+	carmackFarTag     = 0xa8
+	gameMapsSignature = "TED5v1.0"
+)
+
+// tile values of plane 0
+const (
+	wolfFirstDoor   = 90
+	wolfLastDoor    = 101
+	wolfAmbushTile  = 106
+	wolfFirstFloor  = 107
+	wolfElevator    = 21
+	wolfLastFloor   = 143
+	importFloorTile = 0 // wall definition index used for floors and ceilings
+)
+
+// objectSpecials translates plane 1 objects to specials.
+var objectSpecials = map[uint16]Special{
+	19: PlayerA, 20: PlayerA, 21: PlayerA, 22: PlayerA,
+	47: SmallMedkit, 48: SmallMedkit,
+	49: PistolAmmo, 50: Gun, 51: Chaingun,
+}
+
+// plane 1 turn markers, in direction order
+const (
+	wolfFirstTurn = 90
+	wolfLastTurn  = 97
+)
+
+var wolfTurns = [wolfLastTurn - wolfFirstTurn + 1]Direction{East, NorthEast, North, NorthWest, West, SouthWest, South, SouthEast}
+
+// objectActors translates the directions of the plane 1 monsters; each group of 8 objects
+// has the standing monsters facing east, north, west and south, then the patrolling ones.
+var objectActors = map[uint16]Actor{}
+
+func addMonsterObjects(first uint16, special Special) {
+	for i := uint16(0); i < 8; i++ {
+		objectSpecials[first+i] = special
+		objectActors[first+i] = Actor{Facing: [4]Direction{East, North, West, South}[i%4], Patrol: i >= 4}
+	}
+}
+
+func init() {
+	// guards, officers, SS, dogs and mutants of all skill levels, standing and patrolling;
+	// SS and mutants are imported as guards
+	for _, first := range []uint16{108, 126, 144, 162, 180, 198, 216, 234, 252} {
+		addMonsterObjects(first, MonsterSpecial)
+	}
+	for _, first := range []uint16{116, 152, 188} {
+		addMonsterObjects(first, OfficerSpecial)
+	}
+	for _, first := range []uint16{134, 170, 206} {
+		addMonsterObjects(first, DogSpecial)
+	}
+	for _, v := range []uint16{160, 178, 179, 196, 197, 214, 215} {
+		objectSpecials[v] = BossSpecial
+	}
+}
+
+// collectionTiles are the WolfCollection.png texture coordinates used for imported walls; the door texture is excluded.
+var collectionTiles = func() (tiles []WallDef) {
+	for texY := 0; texY < 4; texY++ {
+		for texX := 0; texX < 4; texX++ {
+			if texX == 1 && texY == 0 {
+				continue
+			}
+			x, y := 1-float32(texX)/4, 1-float32(texY)/4
+			tiles = append(tiles, WallDef{x, x - 0.25, y - 0.25, y})
+		}
+	}
+	return
+}()
+
+// gameMapsLevel is the level header found in GAMEMAPS; fields are exported for encoding/binary.
+type gameMapsLevel struct {
+	PlaneStart  [numPlanes]int32
+	PlaneLength [numPlanes]uint16
+	Width       uint16
+	Height      uint16
+	Name        [16]byte
+}
+
+// GameMaps gives access to the levels of original Wolfenstein 3D MAPHEAD and GAMEMAPS files.
+type GameMaps struct {
+	rlewTag  uint16
+	offsets  []int32
+	gameMaps []byte
+}
+
+// OpenGameMaps reads the specified MAPHEAD and GAMEMAPS files, e.g. MAPHEAD.WL1 and GAMEMAPS.WL1.
+func OpenGameMaps(mapHeadFile, gameMapsFile string) (*GameMaps, error) {
+	mapHead, err := ioutil.ReadFile(mapHeadFile)
+	if err != nil {
+		return nil, err
+	}
+	gameMaps, err := ioutil.ReadFile(gameMapsFile)
+	if err != nil {
+		return nil, err
+	}
+	return ReadGameMaps(mapHead, gameMaps)
+}
+
+// ReadGameMaps reads the contents of MAPHEAD and GAMEMAPS files.
+func ReadGameMaps(mapHead, gameMaps []byte) (*GameMaps, error) {
+	if len(mapHead) < 2+4*maxGameMapsLevels {
+		return nil, errors.New("MAPHEAD is too short")
+	}
+	if !bytes.HasPrefix(gameMaps, []byte(gameMapsSignature)) {
+		return nil, errors.New("GAMEMAPS signature not found")
+	}
+
+	gm := GameMaps{gameMaps: gameMaps}
+	gm.rlewTag = binary.LittleEndian.Uint16(mapHead)
+	for i := 0; i < maxGameMapsLevels; i++ {
+		offset := int32(binary.LittleEndian.Uint32(mapHead[2+i*4:]))
+		if offset <= 0 {
+			break
+		}
+		gm.offsets = append(gm.offsets, offset)
+	}
+
+	return &gm, nil
+}
+
+// NumLevels returns the number of levels available.
+func (gm *GameMaps) NumLevels() int {
+	return len(gm.offsets)
+}
+
+// Level translates the specified level (starting from 0) to a map.
+func (gm *GameMaps) Level(n int) (*Map, error) {
+	if n < 0 || n >= len(gm.offsets) {
+		return nil, fmt.Errorf("level %d not found", n)
+	}
+
+	var hdr gameMapsLevel
+	offset := int(gm.offsets[n])
+	if offset+binary.Size(hdr) > len(gm.gameMaps) {
+		return nil, fmt.Errorf("level %d: header out of GAMEMAPS", n)
+	}
+	err := binary.Read(bytes.NewReader(gm.gameMaps[offset:]), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	var planes [2][]uint16
+	for p := range planes {
+		start, length := int(hdr.PlaneStart[p]), int(hdr.PlaneLength[p])
+		if start < 0 || start+length > len(gm.gameMaps) {
+			return nil, fmt.Errorf("level %d: plane %d out of GAMEMAPS", n, p)
+		}
+		planes[p], err = gm.expandPlane(gm.gameMaps[start:start+length], int(hdr.Width)*int(hdr.Height))
+		if err != nil {
+			return nil, fmt.Errorf("level %d: plane %d: %v", n, p, err)
+		}
+	}
+
+	name := string(bytes.TrimRight(hdr.Name[:], "\x00"))
+	return translateLevel(name, int(hdr.Width), int(hdr.Height), planes[0], planes[1])
+}
+
+// expandPlane decompresses a plane, returning size words.
+func (gm *GameMaps) expandPlane(data []byte, size int) ([]uint16, error) {
+	if len(data) < 2 {
+		return nil, errors.New("truncated plane")
+	}
+	carmackLen := int(binary.LittleEndian.Uint16(data))
+
+	rlew, err := carmackExpand(data[2:], carmackLen/2)
+	if err != nil {
+		return nil, err
+	}
+	if len(rlew) < 1 {
+		return nil, errors.New("truncated RLEW data")
+	}
+	if int(rlew[0]) != size*2 {
+		return nil, fmt.Errorf("plane size is %d bytes instead of %d", rlew[0], size*2)
+	}
+
+	return rlewExpand(rlew[1:], gm.rlewTag, size)
+}
+
+// carmackExpand decompresses Carmack compressed data to the specified number of words.
+func carmackExpand(src []byte, length int) ([]uint16, error) {
+	dst := make([]uint16, 0, length)
+	for len(dst) < length {
+		if len(src) < 2 {
+			return nil, errors.New("truncated Carmack data")
+		}
+		count, tag := int(src[0]), src[1]
+		src = src[2:]
+
+		if tag != carmackNearTag && tag != carmackFarTag {
+			dst = append(dst, uint16(tag)<<8|uint16(count))
+			continue
+		}
+
+		if count == 0 {
+			// escaped literal word with a tag as high byte
+			if len(src) < 1 {
+				return nil, errors.New("truncated Carmack data")
+			}
+			dst = append(dst, uint16(tag)<<8|uint16(src[0]))
+			src = src[1:]
+			continue
+		}
+
+		var start int
+		if tag == carmackNearTag {
+			if len(src) < 1 {
+				return nil, errors.New("truncated Carmack data")
+			}
+			start = len(dst) - int(src[0])
+			src = src[1:]
+		} else {
+			if len(src) < 2 {
+				return nil, errors.New("truncated Carmack data")
+			}
+			start = int(binary.LittleEndian.Uint16(src))
+			src = src[2:]
+		}
+		if start < 0 || start >= len(dst) || len(dst)+count > length {
+			return nil, errors.New("invalid Carmack pointer")
+		}
+		// copy word by word, as source and destination may overlap
+		for i := 0; i < count; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	return dst, nil
+}
+
+// rlewExpand decompresses RLEW compressed words to the specified number of words.
+func rlewExpand(src []uint16, tag uint16, length int) ([]uint16, error) {
+	dst := make([]uint16, 0, length)
+	for len(dst) < length {
+		if len(src) < 1 {
+			return nil, errors.New("truncated RLEW data")
+		}
+		if src[0] != tag {
+			dst = append(dst, src[0])
+			src = src[1:]
+			continue
+		}
+
+		if len(src) < 3 {
+			return nil, errors.New("truncated RLEW data")
+		}
+		count, value := int(src[1]), src[2]
+		src = src[3:]
+		if len(dst)+count > length {
+			return nil, errors.New("RLEW run exceeds plane size")
+		}
+		for i := 0; i < count; i++ {
+			dst = append(dst, value)
+		}
+	}
+
+	return dst, nil
+}
+
+func isWolfDoor(tile uint16) bool {
+	return tile >= wolfFirstDoor && tile <= wolfLastDoor
+}
+
+func isWolfFloor(tile uint16) bool {
+	return tile == wolfAmbushTile || (tile >= wolfFirstFloor && tile <= wolfLastFloor)
+}
+
+// translateLevel builds a map from the walls and objects planes; plane words are stored row by row.
+func translateLevel(name string, width, height int, walls, objects []uint16) (*Map, error) {
+	m := &Map{
+		Version:  Version,
+		Title:    name,
+		Width:    width,
+		Height:   height,
+		WallDefs: map[int]WallDef{importFloorTile: collectionTiles[0]},
+	}
+	m.Walls, m.Planes, m.Specials = blankBlock(width, height), blankBlock(width, height), blankBlock(width, height)
+	actors := blankBlock(width, height)
+	var hasActors bool
+
+	tile := func(x, y int) uint16 {
+		if x < 0 || y < 0 || x >= height || y >= width {
+			return 0
+		}
+		return walls[x*width+y]
+	}
+
+	// wall definitions are assigned in order of appearance
+	wallIndices := map[uint16]int{}
+	wallIndex := func(t uint16) (int, error) {
+		if i, ok := wallIndices[t]; ok {
+			return i, nil
+		}
+		i := len(wallIndices) + 1
+		if i >= len(TileAlphabet) {
+			return 0, fmt.Errorf("too many different walls (%d)", i)
+		}
+		wallIndices[t] = i
+		m.WallDefs[i] = collectionTiles[(int(t)-1+len(collectionTiles))%len(collectionTiles)]
+		return i, nil
+	}
+
+	for x := 0; x < height; x++ {
+		for y := 0; y < width; y++ {
+			t := tile(x, y)
+			if !isWolfFloor(t) && !isWolfDoor(t) {
+				continue
+			}
+
+			// walls around this cell use the texture of the first adjacent wall found
+			wall := uint16(1)
+			for _, d := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+				n := tile(x+d[0], y+d[1])
+				if !isWolfFloor(n) && !isWolfDoor(n) && n != 0 {
+					wall = n
+					break
+				}
+			}
+			i, err := wallIndex(wall)
+			if err != nil {
+				return nil, err
+			}
+			m.Walls[x][y], _ = TileChar(i)
+			m.Planes[x][y], _ = TileChar(importFloorTile)
+
+			if isWolfDoor(t) {
+				m.Specials[x][y] = byte(DoorSpecial)
+				continue
+			}
+			object := objects[x*width+y]
+			if s, ok := objectSpecials[object]; ok {
+				m.Specials[x][y] = byte(s)
+			}
+
+			// only standing monsters can be in ambush, as in the original game
+			a := objectActors[object]
+			a.Ambush = t == wolfAmbushTile && a.Facing != NoDirection && !a.Patrol
+			if object >= wolfFirstTurn && object <= wolfLastTurn {
+				a.Turn = wolfTurns[object-wolfFirstTurn]
+			}
+			if c, _ := a.char(); c != ' ' {
+				actors[x][y] = c
+				hasActors = true
+			}
+		}
+	}
+
+	// exits are placed next to the elevator switches
+	for x := 0; x < height; x++ {
+		for y := 0; y < width; y++ {
+			if tile(x, y) != wolfElevator {
+				continue
+			}
+			for _, d := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+				ex, ey := x+d[0], y+d[1]
+				if isWolfFloor(tile(ex, ey)) && m.Specials[ex][ey] == byte(Empty) {
+					m.Specials[ex][ey] = byte(ExitSpecial)
+				}
+			}
+		}
+	}
+
+	if hasActors {
+		m.Actors = actors
+	}
+
+	return m, nil
+}
+
+func blankBlock(width, height int) [][]byte {
+	rows := make([][]byte, height)
+	for x := range rows {
+		rows[x] = bytes.Repeat([]byte{' '}, width)
+	}
+	return rows
+}
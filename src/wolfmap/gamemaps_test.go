@@ -0,0 +1,235 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRLEWTag = 0xabcd
+
+// rlewCompress compresses runs of 3 or more words, as well as any word equal to the tag.
+func rlewCompress(words []uint16) []uint16 {
+	var out []uint16
+	for i := 0; i < len(words); {
+		run := 1
+		for i+run < len(words) && words[i+run] == words[i] {
+			run++
+		}
+		if run >= 3 || words[i] == testRLEWTag {
+			out = append(out, testRLEWTag, uint16(run), words[i])
+		} else {
+			out = append(out, words[i:i+run]...)
+		}
+		i += run
+	}
+	return out
+}
+
+// carmackLiterals encodes words as Carmack data without any pointer.
+func carmackLiterals(words []uint16) []byte {
+	var out []byte
+	for _, w := range words {
+		if hi := byte(w >> 8); hi == carmackNearTag || hi == carmackFarTag {
+			out = append(out, 0, hi, byte(w))
+			continue
+		}
+		out = append(out, byte(w), byte(w>>8))
+	}
+	return out
+}
+
+// encodePlane compresses a plane the way it is stored in GAMEMAPS.
+func encodePlane(words []uint16) []byte {
+	rlew := append([]uint16{uint16(len(words) * 2)}, rlewCompress(words)...)
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, uint16(len(rlew)*2))
+	return append(data, carmackLiterals(rlew)...)
+}
+
+// testGameMaps returns MAPHEAD and GAMEMAPS contents for a single level.
+func testGameMaps(t *testing.T, name string, width, height int, walls, objects []uint16) ([]byte, []byte) {
+	var gameMaps bytes.Buffer
+	gameMaps.WriteString(gameMapsSignature)
+
+	var hdr gameMapsLevel
+	for p, plane := range [numPlanes][]uint16{walls, objects, make([]uint16, len(walls))} {
+		data := encodePlane(plane)
+		hdr.PlaneStart[p] = int32(gameMaps.Len())
+		hdr.PlaneLength[p] = uint16(len(data))
+		gameMaps.Write(data)
+	}
+	hdr.Width, hdr.Height = uint16(width), uint16(height)
+	copy(hdr.Name[:], name)
+
+	mapHead := make([]byte, 2+4*maxGameMapsLevels)
+	binary.LittleEndian.PutUint16(mapHead, testRLEWTag)
+	binary.LittleEndian.PutUint32(mapHead[2:], uint32(gameMaps.Len()))
+	err := binary.Write(&gameMaps, binary.LittleEndian, &hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mapHead, gameMaps.Bytes()
+}
+
+func TestCarmackExpand(t *testing.T) {
+	src := []byte{
+		0x01, 0x00, 0x02, 0x00, // literals
+		0x02, carmackNearTag, 0x02, // copy 2 words from 2 words back
+		0x00, carmackNearTag, 0x05, // escaped literal
+		0x03, carmackFarTag, 0x00, 0x00, // copy 3 words from the start
+	}
+	words, err := carmackExpand(src, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{1, 2, 1, 2, 0xa705, 1, 2, 1}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %v but got %v", expected, words)
+	}
+
+	_, err = carmackExpand([]byte{0x02, carmackFarTag, 0x05, 0x00}, 2)
+	if err == nil {
+		t.Error("expected error for pointer beyond the expanded data")
+	}
+}
+
+func TestRLEWExpand(t *testing.T) {
+	words, err := rlewExpand([]uint16{7, testRLEWTag, 4, 9, 8}, testRLEWTag, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{7, 9, 9, 9, 9, 8}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %v but got %v", expected, words)
+	}
+}
+
+func TestImportGameMaps(t *testing.T) {
+	// '#' is wall 1, '%' is wall 2, 'E' the elevator switch, 'D' a door and '.' floor
+	layout := []string{
+		"########",
+		"#..#...#",
+		"#..D...E",
+		"#..#...#",
+		"#%######",
+	}
+	tiles := map[byte]uint16{'#': 1, '%': 2, 'E': wolfElevator, 'D': 90, '.': 108}
+	const width, height = 8, 5
+
+	walls := make([]uint16, width*height)
+	objects := make([]uint16, width*height)
+	for x, row := range layout {
+		for y := range row {
+			walls[x*width+y] = tiles[row[y]]
+		}
+	}
+	objects[2*width+1] = 19  // player start
+	objects[1*width+5] = 108 // guard standing east, in ambush
+	walls[1*width+5] = wolfAmbushTile
+	objects[3*width+5] = 139 // dog patrolling north
+	objects[3*width+6] = 92  // turn north
+	objects[3*width+1] = 48  // medkit
+	objects[1*width+1] = 25  // table, not imported
+
+	gm, err := ReadGameMaps(testGameMaps(t, "Wolf1 Map1", width, height, walls, objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gm.NumLevels() != 1 {
+		t.Fatalf("expected 1 level but got %d", gm.NumLevels())
+	}
+	m, err := gm.Level(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Title != "Wolf1 Map1" || m.Width != width || m.Height != height {
+		t.Errorf("unexpected title %q or size %dx%d", m.Title, m.Width, m.Height)
+	}
+	expected := []string{
+		"        ",
+		"     e  ",
+		" A d  X ",
+		" m   D  ",
+		"        ",
+	}
+	for x, row := range expected {
+		if string(m.Specials[x]) != row {
+			t.Errorf("SPECIALS row %d: expected %q but got %q", x, row, m.Specials[x])
+		}
+	}
+	expected = []string{
+		"        ",
+		"     >  ",
+		"        ",
+		"     N8 ",
+		"        ",
+	}
+	for x, row := range expected {
+		if string(m.Actors[x]) != row {
+			t.Errorf("ACTORS row %d: expected %q but got %q", x, row, m.Actors[x])
+		}
+	}
+	if m.WallTexCoords(3, 1) == m.WallTexCoords(1, 1) {
+		t.Error("cells next to different walls should have different textures")
+	}
+	if problems := Check(m); len(problems) != 0 {
+		t.Errorf("imported map has problems: %v", problems)
+	}
+
+	// converted maps can be read back
+	var buf bytes.Buffer
+	err = m.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := Read(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.Title != m.Title || !reflect.DeepEqual(m2.WallDefs, m.WallDefs) ||
+		!reflect.DeepEqual(m2.Walls, m.Walls) || !reflect.DeepEqual(m2.Planes, m.Planes) || !reflect.DeepEqual(m2.Specials, m.Specials) ||
+		!reflect.DeepEqual(m2.Actors, m.Actors) {
+		t.Errorf("map read back differs:\n%s", buf.String())
+	}
+}
+
+func TestReadGameMapsErrors(t *testing.T) {
+	mapHead, gameMaps := testGameMaps(t, "x", 1, 1, []uint16{1}, []uint16{0})
+	if _, err := ReadGameMaps(mapHead[:10], gameMaps); err == nil {
+		t.Error("expected error for truncated MAPHEAD")
+	}
+	if _, err := ReadGameMaps(mapHead, gameMaps[1:]); err == nil {
+		t.Error("expected error for missing GAMEMAPS signature")
+	}
+	gm, err := ReadGameMaps(mapHead, gameMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gm.Level(1); err == nil {
+		t.Error("expected error for missing level")
+	}
+}
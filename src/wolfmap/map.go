@@ -0,0 +1,420 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+// Package wolfmap reads and validates the WolfenGo text map format.
+package wolfmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type Special byte
+
+const (
+	UnknownSpecial         Special = 0
+	PlayerA                Special = 'A'
+	PlayerB                Special = 'B'
+	Pistol                 Special = 'P'
+	Gun                    Special = 'G'
+	Rocket                 Special = 'R'
+	Plasma                 Special = 'S'
+	Chaingun               Special = 'C'
+	PistolAmmo             Special = 'I'
+	GunAmmo                Special = 'U'
+	RocketAmmo             Special = 'O'
+	PlasmaAmmo             Special = 'L'
+	BigMedkit              Special = 'M'
+	SmallMedkit            Special = 'm'
+	LightAmplificatorVisor Special = 'V'
+	DoorSpecial            Special = 'd'
+	MonsterSpecial         Special = 'e'
+	DogSpecial             Special = 'D'
+	OfficerSpecial         Special = 'o'
+	BossSpecial            Special = 'H'
+	ExitSpecial            Special = 'X'
+	SecretSpecial          Special = '*'
+	Empty                  Special = ' '
+)
+
+// monsterSpecials are the specials placing monsters, one for each type.
+var monsterSpecials = map[Special]bool{
+	MonsterSpecial: true,
+	DogSpecial:     true,
+	OfficerSpecial: true,
+	BossSpecial:    true,
+}
+
+// IsMonster returns true for the specials placing monsters.
+func (s Special) IsMonster() bool {
+	return monsterSpecials[s]
+}
+
+type WallDef [4]float32
+
+func (wd *WallDef) String() string {
+	return fmt.Sprintf("{%.2f, %.2f, %.2f, %.2f}", wd[0], wd[1], wd[2], wd[3])
+}
+
+type block int
+
+const (
+	wallsBlock block = iota
+	planesBlock
+	specialsBlock
+	// optional, see Actor
+	actorsBlock
+	numBlocks
+)
+
+var blockNames = [numBlocks]string{"MAP", "PLANES", "SPECIALS", "ACTORS"}
+
+// Version is the latest map format version; version 1 maps have no header
+// and are declared square with 'lengthmap'.
+const Version = 2
+
+type Map struct {
+	Version int
+	Title   string
+	Author  string
+	ParTime time.Duration
+	// file name of the next level, if any
+	Next string
+
+	WallDefs                map[int]WallDef
+	Walls, Planes, Specials [][]byte
+	// nil when the map has no ACTORS block
+	Actors [][]byte
+	// Width is the number of columns (y coordinate) and Height the number of rows (x coordinate)
+	Width, Height int
+
+	// line number of the first row of each block, for error reporting
+	blockLines [numBlocks]int
+}
+
+type Error struct {
+	FileName string
+	Err      error
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("map(%s): %v", e.FileName, e.Err)
+}
+
+// SyntaxError is returned when the map file cannot be parsed.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at line %d", se.Msg, se.Line)
+}
+
+func syntaxErrorf(line int, format string, a ...interface{}) error {
+	return &SyntaxError{line, fmt.Sprintf(format, a...)}
+}
+
+// Load reads the map from the specified file; maps with walkable cells on
+// the edges are rejected, as they would let the player walk out of the map.
+func Load(fileName string) (*Map, error) {
+	m, err := ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.checkSealed()
+	if err != nil {
+		return nil, Error{fileName, err}
+	}
+
+	return m, nil
+}
+
+// ReadFile reads the map from the specified file without any semantic
+// validation, so that all problems can be reported with Check.
+func ReadFile(fileName string) (*Map, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, Error{fileName, err}
+	}
+	defer f.Close()
+
+	m, err := Read(f)
+	if err != nil {
+		return nil, Error{fileName, err}
+	}
+	return m, nil
+}
+
+// checkSealed returns an error for the first walkable cell found on the map edges.
+func (m *Map) checkSealed() error {
+	for x := 0; x < m.Height; x++ {
+		for y := 0; y < m.Width; y++ {
+			if m.isEdge(x, y) && !m.IsEmpty(x, y) {
+				line, col := m.pos(wallsBlock, x, y)
+				return fmt.Errorf("map is not sealed: walkable cell at line %d, column %d touches the edge", line, col)
+			}
+		}
+	}
+	return nil
+}
+
+// lineReader reads a map file line by line, keeping track of the line number.
+type lineReader struct {
+	scanner *bufio.Scanner
+	lineNum int
+	line    string
+}
+
+// next reads the next line; at the end of file the current line is blank.
+func (lr *lineReader) next() bool {
+	lr.lineNum++
+	if !lr.scanner.Scan() {
+		lr.line = ""
+		return false
+	}
+	lr.line = lr.scanner.Text()
+	return true
+}
+
+// Read reads a map; syntax errors are returned as soon as they are found.
+func Read(r io.Reader) (*Map, error) {
+	m := &Map{WallDefs: map[int]WallDef{}, Version: 1}
+	lr := lineReader{scanner: bufio.NewScanner(r)}
+
+	lr.next()
+	if strings.HasPrefix(lr.line, "wolfmap") {
+		err := m.readHeader(&lr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for ; strings.HasPrefix(lr.line, "wall"); lr.next() {
+		var wallIndex int
+		var coords WallDef
+		read, _ := fmt.Sscanf(lr.line, "wall%d {%f,%f,%f,%f}", &wallIndex, &coords[0], &coords[1], &coords[2], &coords[3])
+		if read != 5 {
+			return nil, syntaxErrorf(lr.lineNum, "invalid wall row (read %d fields)", read)
+		}
+		if _, ok := m.WallDefs[wallIndex]; ok {
+			return nil, syntaxErrorf(lr.lineNum, "duplicate wall%d declaration", wallIndex)
+		}
+		if wallIndex < 0 || (m.Version > 1 && wallIndex >= len(TileAlphabet)) || wallIndex > 0xff-'0' {
+			return nil, syntaxErrorf(lr.lineNum, "wall%d cannot be used in the map", wallIndex)
+		}
+
+		m.WallDefs[wallIndex] = coords
+	}
+
+	if m.Version == 1 {
+		// read map size
+		var sz int
+		read, _ := fmt.Sscanf(lr.line, "lengthmap %d", &sz)
+		if read != 1 || sz <= 0 {
+			return nil, syntaxErrorf(lr.lineNum, "no valid lengthmap declaration")
+		}
+		m.Width, m.Height = sz, sz
+		lr.next()
+	}
+
+	// now read all map data
+	var err error
+	m.Walls, err = m.readBlock(&lr, wallsBlock)
+	if err != nil {
+		return nil, err
+	}
+	m.Planes, err = m.readBlock(&lr, planesBlock)
+	if err != nil {
+		return nil, err
+	}
+	m.Specials, err = m.readBlock(&lr, specialsBlock)
+	if err != nil {
+		return nil, err
+	}
+	if lr.line == blockNames[actorsBlock]+":" {
+		m.Actors, err = m.readBlock(&lr, actorsBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// readHeader reads the version line and the header fields of a versioned map,
+// leaving the first line after the header as current line.
+func (m *Map) readHeader(lr *lineReader) error {
+	_, err := fmt.Sscanf(lr.line, "wolfmap %d", &m.Version)
+	if err != nil {
+		return syntaxErrorf(lr.lineNum, "invalid version declaration")
+	}
+	if m.Version < 2 || m.Version > Version {
+		return syntaxErrorf(lr.lineNum, "unsupported map version %d", m.Version)
+	}
+
+	for lr.next() {
+		fields := strings.Fields(lr.line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "wall") || fields[0] == blockNames[wallsBlock]+":" {
+			break
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lr.line), fields[0]))
+		switch fields[0] {
+		case "title":
+			m.Title = value
+		case "author":
+			m.Author = value
+		case "next":
+			m.Next = value
+		case "size":
+			read, _ := fmt.Sscanf(value, "%d %d", &m.Width, &m.Height)
+			if read != 2 || m.Width <= 0 || m.Height <= 0 {
+				return syntaxErrorf(lr.lineNum, "invalid size declaration")
+			}
+		case "par":
+			var minutes, seconds int
+			read, _ := fmt.Sscanf(value, "%d:%d", &minutes, &seconds)
+			if read != 2 || minutes < 0 || seconds < 0 || seconds > 59 {
+				return syntaxErrorf(lr.lineNum, "invalid par time declaration (expected mm:ss)")
+			}
+			m.ParTime = time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+		default:
+			return syntaxErrorf(lr.lineNum, "unknown header field %q", fields[0])
+		}
+	}
+
+	if m.Width == 0 {
+		return syntaxErrorf(lr.lineNum, "missing size declaration")
+	}
+
+	return nil
+}
+
+// readBlock reads a block of the map, starting from its declaration in the current line.
+func (m *Map) readBlock(lr *lineReader, b block) ([][]byte, error) {
+	if lr.line != blockNames[b]+":" {
+		return nil, syntaxErrorf(lr.lineNum, "could not match %s declaration", blockNames[b])
+	}
+	m.blockLines[b] = lr.lineNum + 1
+
+	rows := make([][]byte, m.Height)
+	for row := 0; row < m.Height; row++ {
+		if !lr.next() {
+			if err := lr.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, syntaxErrorf(lr.lineNum, "not enough data")
+		}
+		if len(lr.line) < m.Width {
+			return nil, syntaxErrorf(lr.lineNum, "not enough data")
+		}
+		if len(lr.line) > m.Width {
+			return nil, syntaxErrorf(lr.lineNum, "invalid line termination")
+		}
+		rows[row] = []byte(lr.line)
+	}
+	lr.next()
+
+	return rows, nil
+}
+
+// InBounds returns true if x,y is a cell of the map.
+func (m *Map) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.Height && y < m.Width
+}
+
+func (m *Map) isEdge(x, y int) bool {
+	return x == 0 || y == 0 || x == m.Height-1 || y == m.Width-1
+}
+
+// Wall returns the MAP value at x,y; cells out of the map are blank.
+func (m *Map) Wall(x, y int) byte {
+	if !m.InBounds(x, y) {
+		return ' '
+	}
+	return m.Walls[x][y]
+}
+
+// Plane returns the PLANES value at x,y; cells out of the map are blank.
+func (m *Map) Plane(x, y int) byte {
+	if !m.InBounds(x, y) {
+		return ' '
+	}
+	return m.Planes[x][y]
+}
+
+// Special returns the special at x,y; cells out of the map are blank.
+func (m *Map) Special(x, y int) Special {
+	if !m.InBounds(x, y) {
+		return Empty
+	}
+	return Special(m.Specials[x][y])
+}
+
+// IsEmpty returns true for solid cells, that is cells without walls, planes or specials.
+// Cells out of the map are considered solid.
+func (m *Map) IsEmpty(x, y int) bool {
+	return m.Special(x, y) == Empty && m.Wall(x, y) == ' ' && m.Plane(x, y) == ' '
+}
+
+// TileAlphabet lists, in index order, the characters used in the MAP and PLANES
+// blocks of versioned maps to refer to the 'wallN' definitions; e.g. 'a' is wall10
+// and 'A' is wall36.
+// Maps without header use instead the ASCII offset from '0', thus ':' is wall10.
+const TileAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// TileIndex returns the wall definition index for a MAP or PLANES character.
+func (m *Map) TileIndex(c byte) (int, bool) {
+	if m.Version == 1 {
+		if c < '0' {
+			return 0, false
+		}
+		return int(c - '0'), true
+	}
+
+	i := strings.IndexByte(TileAlphabet, c)
+	return i, i != -1
+}
+
+// TileChar returns the character for the specified wall definition index in versioned maps.
+func TileChar(index int) (byte, bool) {
+	if index < 0 || index >= len(TileAlphabet) {
+		return 0, false
+	}
+	return TileAlphabet[index], true
+}
+
+func (m *Map) tileDef(c byte) WallDef {
+	i, _ := m.TileIndex(c)
+	return m.WallDefs[i]
+}
+
+func (m *Map) WallTexCoords(x, y int) WallDef {
+	return m.tileDef(m.Wall(x, y))
+}
+
+func (m *Map) PlaneTexCoords(x, y int) WallDef {
+	return m.tileDef(m.Plane(x, y))
+}
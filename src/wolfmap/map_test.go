@@ -0,0 +1,215 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutOfBoundsCellsAreSolid(t *testing.T) {
+	m, err := Read(strings.NewReader(testMap([]string{
+		"11",
+		"11",
+	}, []string{
+		"A ",
+		" X",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range [][2]int{{-1, 0}, {0, -1}, {2, 0}, {0, 2}, {-5, 7}} {
+		if !m.IsEmpty(c[0], c[1]) {
+			t.Errorf("cell %d,%d should be solid", c[0], c[1])
+		}
+		if m.Wall(c[0], c[1]) != ' ' || m.Plane(c[0], c[1]) != ' ' || m.Special(c[0], c[1]) != Empty {
+			t.Errorf("cell %d,%d should be blank", c[0], c[1])
+		}
+	}
+	if m.IsEmpty(1, 1) || m.Special(0, 0) != PlayerA {
+		t.Error("cells in bounds are not read correctly")
+	}
+}
+
+func TestLoadRejectsUnsealedMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wolfmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "unsealed.map")
+	err = ioutil.WriteFile(fileName, []byte(testMap([]string{
+		"   ",
+		" 11",
+		"   ",
+	}, []string{
+		"   ",
+		" AX",
+		"   ",
+	})), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(fileName)
+	if err == nil || !strings.Contains(err.Error(), "line 5, column 3") {
+		t.Errorf("expected unsealed map error but got %v", err)
+	}
+
+	_, err = ReadFile(fileName)
+	if err != nil {
+		t.Errorf("unsealed map should be readable without validation: %v", err)
+	}
+}
+
+func TestReadVersionedHeader(t *testing.T) {
+	m, err := Read(strings.NewReader(`wolfmap 2
+title   The Bunker
+author  gdm85
+size    5 3
+par     01:30
+next    level2.map
+wall1   {1.00,0.75,0.75,1.00}
+MAP:
+     
+ 111 
+     
+PLANES:
+     
+ 111 
+     
+SPECIALS:
+     
+ A X 
+     
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != 2 || m.Title != "The Bunker" || m.Author != "gdm85" || m.Next != "level2.map" || m.ParTime != 90*time.Second {
+		t.Errorf("header not read correctly: %+v", m)
+	}
+	if m.Width != 5 || m.Height != 3 || len(m.Walls) != 3 || len(m.Walls[0]) != 5 {
+		t.Errorf("expected a 5x3 map but got %dx%d", m.Width, m.Height)
+	}
+	if m.Special(1, 3) != ExitSpecial {
+		t.Error("exit not found")
+	}
+	if problems := Check(m); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		line int
+	}{
+		{"wolfmap 3\n", 1},
+		{"wolfmap 2\ntitle x\nMAP:\n", 3},
+		{"wolfmap 2\nsize 3\n", 2},
+		{"wolfmap 2\nsize 3 3\npar 90\n", 3},
+		{"wolfmap 2\nsize 3 3\ncolor red\n", 3},
+	} {
+		_, err := Read(strings.NewReader(tc.data))
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected a syntax error but got %v", tc.data, err)
+			continue
+		}
+		if se.Line != tc.line {
+			t.Errorf("%q: expected error at line %d but got %v", tc.data, tc.line, se)
+		}
+	}
+}
+
+func TestTileAlphabet(t *testing.T) {
+	data := `wolfmap 2
+size    5 3
+wall1   {1.00,0.75,0.75,1.00}
+wall10  {0.25,0.00,0.00,0.25}
+wall36  {0.50,0.25,0.50,0.75}
+wall61  {0.75,0.50,0.25,0.50}
+MAP:
+     
+ 1aA 
+     
+PLANES:
+     
+ Z1a 
+     
+SPECIALS:
+     
+ A X 
+     
+`
+	m, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.WallTexCoords(1, 2) != m.WallDefs[10] || m.WallTexCoords(1, 3) != m.WallDefs[36] || m.PlaneTexCoords(1, 1) != m.WallDefs[61] {
+		t.Error("tile characters not mapped to the right wall definitions")
+	}
+	if problems := Check(m); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	for i := 0; i < len(TileAlphabet); i++ {
+		c, ok := TileChar(i)
+		if !ok {
+			t.Fatalf("no tile character for index %d", i)
+		}
+		if j, _ := m.TileIndex(c); j != i {
+			t.Errorf("tile character %c maps to %d instead of %d", c, j, i)
+		}
+	}
+
+	_, err = Read(strings.NewReader("wolfmap 2\nsize 3 3\nwall62  {0.75,0.50,0.25,0.50}\n"))
+	if err == nil {
+		t.Error("expected an error for wall index beyond tile alphabet")
+	}
+}
+
+func TestLegacyTileOffset(t *testing.T) {
+	m, err := Read(strings.NewReader(testMap([]string{
+		"   ",
+		" : ",
+		"   ",
+	}, []string{
+		"   ",
+		" A ",
+		"   ",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i, ok := m.TileIndex(':'); !ok || i != 10 {
+		t.Errorf("expected ':' to be wall10 in maps without header, got %d", i)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+WolfenGo - https://github.com/gdm85/wolfengo
+Copyright (C) 2016~2019 gdm85
+
+This program is free software; you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation; either version 2 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program; if not, write to the Free Software Foundation, Inc.,
+51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+*/
+
+package wolfmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Write writes the map in the latest format version; versioned maps can
+// thus be produced from maps without header or from imported levels.
+func (m *Map) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "wolfmap %d\n", Version)
+	if m.Title != "" {
+		fmt.Fprintf(bw, "title %s\n", m.Title)
+	}
+	if m.Author != "" {
+		fmt.Fprintf(bw, "author %s\n", m.Author)
+	}
+	fmt.Fprintf(bw, "size %d %d\n", m.Width, m.Height)
+	if m.ParTime != 0 {
+		fmt.Fprintf(bw, "par %02d:%02d\n", m.ParTime/time.Minute, m.ParTime%time.Minute/time.Second)
+	}
+	if m.Next != "" {
+		fmt.Fprintf(bw, "next %s\n", m.Next)
+	}
+
+	indices := make([]int, 0, len(m.WallDefs))
+	for i := range m.WallDefs {
+		if _, ok := TileChar(i); !ok {
+			return fmt.Errorf("wall%d cannot be used in a version %d map", i, Version)
+		}
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		wd := m.WallDefs[i]
+		fmt.Fprintf(bw, "wall%d %s\n", i, wd.String())
+	}
+
+	for b, rows := range [numBlocks][][]byte{m.Walls, m.Planes, m.Specials, m.Actors} {
+		if rows == nil {
+			continue
+		}
+		fmt.Fprintf(bw, "%s:\n", blockNames[b])
+		for _, row := range rows {
+			line := make([]byte, len(row))
+			for y, c := range row {
+				line[y] = c
+				if block(b) == specialsBlock || block(b) == actorsBlock || c == ' ' {
+					continue
+				}
+				// tile characters are re-encoded for maps without header
+				if i, ok := m.TileIndex(c); ok {
+					line[y], ok = TileChar(i)
+					if !ok {
+						return fmt.Errorf("wall%d cannot be used in a version %d map", i, Version)
+					}
+				}
+			}
+			bw.Write(line)
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}